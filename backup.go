@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"todo_restapi/internal/storage"
+)
+
+// backupDatabase implements `todo_restapi backup <file>`. It is safe to run
+// next to a serving instance.
+func backupDatabase(args []string, stdout io.Writer) error {
+
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	cfg, err := loadConfig(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: backup [flags] <file>")
+	}
+
+	database, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer database.CloseStorage()
+
+	if err := database.Backup(context.Background(), flags.Arg(0)); err != nil {
+		return fmt.Errorf("Backup: %w", err)
+	}
+
+	_, err = fmt.Fprintf(stdout, "backed up %s to %s\n", cfg.StoragePath, flags.Arg(0))
+	return err
+}
+
+// restoreDatabase implements `todo_restapi restore <file>`. The server has
+// to be stopped, otherwise it keeps writing to the replaced file.
+func restoreDatabase(args []string, stdout io.Writer) error {
+
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	cfg, err := loadConfig(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: restore [flags] <file>")
+	}
+
+	if err := storage.Restore(flags.Arg(0), cfg.StoragePath); err != nil {
+		return fmt.Errorf("Restore: %w", err)
+	}
+
+	_, err = fmt.Fprintf(stdout, "restored %s from %s\n", cfg.StoragePath, flags.Arg(0))
+	return err
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"todo_restapi/internal/config"
+	"todo_restapi/internal/storage"
+)
+
+const usage = `usage: todo_restapi [command] [flags]
+
+commands:
+  serve          run the server (the default)
+  migrate        apply pending database migrations
+  backup         write a consistent copy of the database to a file
+  restore        replace the database with a backup, the server must be stopped
+  export         print the tasks of a user as JSON
+  import         add tasks from a JSON file made by export
+  hash-password  print a bcrypt or argon2id hash for TODO_PASSWORD
+  signing-key    print a new private key for TODO_SIGNING_KEYS
+  token          mint an access token for testing
+
+Every command except hash-password and signing-key reads the same
+configuration as serve; run "todo_restapi <command> -h" for its flags.
+`
+
+func runCommand(name string, args []string) {
+
+	var err error
+	switch name {
+	case "serve":
+		err = run(args)
+	case "migrate":
+		err = migrateDatabase(args, os.Stdout)
+	case "backup":
+		err = backupDatabase(args, os.Stdout)
+	case "restore":
+		err = restoreDatabase(args, os.Stdout)
+	case "export":
+		err = exportTasks(args, os.Stdout)
+	case "import":
+		err = importTasks(args, os.Stdin, os.Stdout)
+	case "hash-password":
+		err = hashPassword(args, os.Stdin, os.Stdout)
+	case "signing-key":
+		err = generateSigningKey(args, os.Stdout)
+	case "token":
+		err = mintToken(args, os.Stdout)
+	case "help":
+		fmt.Print(usage)
+	default:
+		err = fmt.Errorf("unknown command %q\n\n%s", name, usage)
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// loadConfig parses the flags of a command together with the configuration
+// flags shared by every command, and loads the configuration.
+func loadConfig(flags *flag.FlagSet, args []string) (*config.Config, error) {
+
+	configFlags := config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	return config.Load(configFlags)
+}
+
+// openDatabase opens the configured database, applying pending migrations.
+// TODO_QUERY_TIMEOUT is meant for API requests; a backup or an export of a
+// large database may take as long as it needs.
+func openDatabase(cfg *config.Config) (*storage.Storage, error) {
+
+	database, err := storage.OpenStorage(cfg.StoragePath)
+	if err != nil {
+		return nil, fmt.Errorf("OpenStorage: %w", err)
+	}
+
+	database.SetQueryTimeout(0)
+	return database, nil
+}
+
+// findUser resolves the -user flag of a command: a login, or the built-in
+// admin when it is empty.
+func findUser(ctx context.Context, database *storage.Storage, login string) (int64, error) {
+
+	if login == "" {
+		return storage.AdminUserID, nil
+	}
+
+	user, err := database.GetUserByLogin(ctx, login)
+	if err != nil {
+		return 0, fmt.Errorf("GetUserByLogin: %w", err)
+	}
+
+	return user.ID, nil
+}
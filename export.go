@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"todo_restapi/internal/constants"
+	"todo_restapi/internal/models"
+	"todo_restapi/internal/services"
+	"todo_restapi/internal/storage"
+)
+
+// taskExport has the shape of the GET /api/tasks response, so a file written
+// by export can be read back by import.
+type taskExport struct {
+	Tasks []models.Task `json:"tasks"`
+}
+
+// exportTasks implements `todo_restapi export [-user login] [-o file]` and
+// writes every personal task of the user, the admin by default.
+func exportTasks(args []string, stdout io.Writer) error {
+
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	login := flags.String("user", "", "login of the user, the admin by default")
+	output := flags.String("o", "", "write to the file instead of stdout")
+	cfg, err := loadConfig(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return errors.New("usage: export [-user login] [-o file] [flags]")
+	}
+
+	database, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer database.CloseStorage()
+
+	ctx := context.Background()
+
+	userID, err := findUser(ctx, database, *login)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := database.ExportTasks(ctx, storage.TaskScope{OwnerID: userID})
+	if err != nil {
+		return fmt.Errorf("ExportTasks: %w", err)
+	}
+
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("create error: %w", err)
+		}
+		defer file.Close()
+		stdout = file
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(taskExport{Tasks: tasks})
+}
+
+// importTasks implements `todo_restapi import [-user login] <file>`, with "-"
+// for stdin. Either every task is added or, if one of them is invalid, none.
+func importTasks(args []string, stdin io.Reader, stdout io.Writer) error {
+
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	login := flags.String("user", "", "login of the user, the admin by default")
+	cfg, err := loadConfig(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: import [-user login] [flags] <file>")
+	}
+
+	input := stdin
+	if flags.Arg(0) != "-" {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return fmt.Errorf("open error: %w", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	var data taskExport
+	if err := json.NewDecoder(input).Decode(&data); err != nil {
+		return fmt.Errorf("decode error: %w", err)
+	}
+
+	for i, task := range data.Tasks {
+		if err := validateImportedTask(task); err != nil {
+			return fmt.Errorf("task %d (%q): %w", i+1, task.Title, err)
+		}
+	}
+
+	database, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer database.CloseStorage()
+
+	ctx := context.Background()
+
+	userID, err := findUser(ctx, database, *login)
+	if err != nil {
+		return err
+	}
+
+	scope := storage.TaskScope{OwnerID: userID}
+	err = database.WithTx(ctx, func(tx *storage.Storage) error {
+		for _, task := range data.Tasks {
+			if _, err := tx.AddTask(ctx, scope, task); err != nil {
+				return fmt.Errorf("AddTask: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "imported %d tasks\n", len(data.Tasks))
+	return err
+}
+
+// validateImportedTask checks a task like the API does, but keeps dates in
+// the past: an export is restored as it was, not moved to today.
+func validateImportedTask(task models.Task) error {
+
+	if task.Title == "" {
+		return errors.New("title is empty")
+	}
+
+	if _, err := time.Parse(constants.DateFormat, task.Date); err != nil {
+		return fmt.Errorf("invalid date: %w", err)
+	}
+
+	if task.Repeat != "" {
+		if _, err := services.NextDate(time.Now(), task.Date, task.Repeat); err != nil {
+			return fmt.Errorf("invalid repeat: %w", err)
+		}
+	}
+
+	return nil
+}
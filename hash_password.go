@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"todo_restapi/internal/services"
+)
+
+// hashPassword implements `todo_restapi hash-password [-argon2id] [password]`.
+// Without an argument the password is read from the first line of stdin, so it
+// does not end up in the shell history.
+func hashPassword(args []string, stdin io.Reader, stdout io.Writer) error {
+
+	flags := flag.NewFlagSet("hash-password", flag.ContinueOnError)
+	useArgon2id := flags.Bool("argon2id", false, "use argon2id instead of bcrypt")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var password string
+	switch flags.NArg() {
+	case 0:
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("read password error: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	case 1:
+		password = flags.Arg(0)
+	default:
+		return errors.New("usage: hash-password [-argon2id] [password]")
+	}
+
+	if password == "" {
+		return errors.New("empty password")
+	}
+
+	var hash string
+	var err error
+	if *useArgon2id {
+		hash, err = services.HashPasswordArgon2id(password)
+	} else {
+		hash, err = services.HashPassword(password)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(stdout, hash)
+	return err
+}
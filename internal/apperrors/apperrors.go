@@ -0,0 +1,152 @@
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindMethodNotAllowed
+	KindConflict
+	KindUnprocessable
+	KindTooManyRequests
+	KindUnavailable
+)
+
+func (k Kind) Status() int {
+
+	switch k {
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case KindConflict:
+		return http.StatusConflict
+	case KindUnprocessable:
+		return http.StatusUnprocessableEntity
+	case KindTooManyRequests:
+		return http.StatusTooManyRequests
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is a domain error with a stable machine-readable Code. Message is safe
+// to show to clients; Err keeps the underlying cause for logs only.
+type Error struct {
+	Kind       Kind
+	Code       string
+	Message    string
+	Fields     []FieldError
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors of the same kind and code, so that errors.Is sees
+// through Wrap, which returns a copy.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind && t.Code == e.Code
+}
+
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+func Field(field string, code string, message string) FieldError {
+	return FieldError{Field: field, Code: code, Message: message}
+}
+
+func Validation(code string, message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message, Fields: fields}
+}
+
+func Unauthorized(code string, message string) *Error {
+	return &Error{Kind: KindUnauthorized, Code: code, Message: message}
+}
+
+func Forbidden(code string, message string) *Error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+func NotFound(code string, message string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message}
+}
+
+func MethodNotAllowed() *Error {
+	return &Error{Kind: KindMethodNotAllowed, Code: "method_not_allowed", Message: "invalid method"}
+}
+
+func Conflict(code string, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+func Unprocessable(code string, message string) *Error {
+	return &Error{Kind: KindUnprocessable, Code: code, Message: message}
+}
+
+func TooManyRequests(code string, message string, retryAfter time.Duration) *Error {
+	return &Error{Kind: KindTooManyRequests, Code: code, Message: message, RetryAfter: retryAfter}
+}
+
+func Unavailable(code string, message string) *Error {
+	return &Error{Kind: KindUnavailable, Code: code, Message: message}
+}
+
+// Internal hides err from the client. A query that ran out of time is not a
+// bug, so it is reported as a retryable 503 instead.
+func Internal(err error) *Error {
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Unavailable("query_timeout", "the database did not respond in time, try again").Wrap(err)
+	}
+
+	return &Error{Kind: KindInternal, Code: "internal_error", Message: "internal server error", Err: err}
+}
+
+// From returns err as an *Error, treating anything untyped as internal.
+func From(err error) *Error {
+
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	return Internal(err)
+}
@@ -1,61 +1,184 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+const (
+	ModeDevelopment = "development"
+	ModeProduction  = "production"
+
+	DefaultPassword  = "12345"
+	DefaultSecretKey = "my_secret_key"
+)
+
 type Config struct {
+	// Mode is "development" or "production". Production refuses to start
+	// with the default password or secret key.
+	Mode string
+
 	Port        string
 	StoragePath string
 	Password    string
 	SecretKey   string
+
+	// SigningKeys are PEM files with RS256 or EdDSA keys. The first one signs
+	// access tokens, the rest only verify them during a rotation. Without
+	// them tokens are signed with SecretKey (HS256).
+	SigningKeys []string
+
+	// QueryTimeout bounds every storage call on top of the request context.
+	QueryTimeout time.Duration
+
+	IdempotencyTTL    time.Duration
+	AllowRegistration bool
+
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// On SIGINT/SIGTERM the server keeps serving for ShutdownDelay, so that
+	// load balancers notice it is going away, then waits up to
+	// ShutdownTimeout for in-flight requests.
+	ShutdownDelay   time.Duration
+	ShutdownTimeout time.Duration
+
+	// MetricsToken, when set, has to be sent as a bearer token to /metrics.
+	MetricsToken string
+
+	// TracingExporter is "otlp", "stdout" or empty to export no spans.
+	TracingExporter string
+
+	// LogFormat is "text" or "json".
+	LogFormat string
+	LogLevel  slog.Level
+
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+}
+
+func defaults() *Config {
+	return &Config{
+		Mode:              ModeDevelopment,
+		Port:              ":7540",
+		StoragePath:       "./scheduler.db",
+		Password:          DefaultPassword,
+		SecretKey:         DefaultSecretKey,
+		QueryTimeout:      5 * time.Second,
+		IdempotencyTTL:    24 * time.Hour,
+		AllowRegistration: true,
+		AccessTokenTTL:    15 * time.Minute,
+		RefreshTokenTTL:   30 * 24 * time.Hour,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   30 * time.Second,
+		LogFormat:         "text",
+		LogLevel:          slog.LevelInfo,
+	}
 }
 
-func LoadConfig() *Config {
+// LoadConfig reads the configuration without command-line flags.
+func LoadConfig() (*Config, error) {
+	return Load(nil)
+}
+
+// Load builds the configuration from, in increasing order of precedence,
+// the built-in defaults, the config file (-config or TODO_CONFIG), the
+// environment including .env, and the flags given on the command line.
+// Every invalid value is reported, not only the first one.
+func Load(flags *Flags) (*Config, error) {
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn(".env file could not be read", "error", err)
+	}
 
-	config := &Config{}
+	config := defaults()
+	var errs []error
 
-	if err := godotenv.Load(); err != nil {
-		fmt.Printf("no .env file found: %v\n", err)
+	if path := FilePath(flags); path != "" {
+		values, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, apply(config, values, "config file "+path)...)
 	}
 
-	port, exists := os.LookupEnv("TODO_PORT")
-	if !exists || port == "" {
-		fmt.Println("no port in .env, will use default port (:7540)")
-		config.Port = ":7540"
-	} else {
-		if port[0] != ':' {
-			port = ":" + port
+	env := map[string]string{}
+	for _, s := range settings {
+		if value := os.Getenv(s.env); value != "" {
+			env[s.key()] = value
 		}
-		config.Port = port
 	}
+	errs = append(errs, apply(config, env, "environment")...)
+
+	if flags != nil {
+		errs = append(errs, apply(config, flags.values(), "flag")...)
+	}
+
+	errs = append(errs, config.Validate()...)
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return config, nil
+}
+
+// FilePath is the config file given by -config or else by TODO_CONFIG, empty
+// when there is none.
+func FilePath(flags *Flags) string {
+
+	if flags != nil && flags.File != "" {
+		return flags.File
+	}
+
+	return os.Getenv("TODO_CONFIG")
+}
+
+// Validate checks the settings that depend on each other and, in production
+// mode, refuses the default credentials. The secret key signs tokens when no
+// SigningKeys are configured and encrypts the OIDC sign-in cookie, so its
+// default is accepted only when it is used for neither.
+func (c *Config) Validate() []error {
 
-	storagePath, exists := os.LookupEnv("TODO_DBFILE")
-	if !exists || storagePath == "" {
-		fmt.Println("no path in .env, will use default path (./scheduler.db)")
-		config.StoragePath = "./scheduler.db"
-	} else {
-		config.StoragePath = storagePath
+	var errs []error
+
+	if c.OIDCIssuer != "" && (c.OIDCClientID == "" || c.OIDCRedirectURL == "") {
+		errs = append(errs, errors.New("TODO_OIDC_ISSUER requires TODO_OIDC_CLIENT_ID and TODO_OIDC_REDIRECT_URL"))
 	}
 
-	password, exists := os.LookupEnv("TODO_PASSWORD")
-	if !exists || password == "" {
-		fmt.Println("no password in .env, must set for auth, will use default password (12345)")
-		config.Password = "12345"
-	} else {
-		config.Password = password
+	defaultPassword := c.Password == DefaultPassword
+	defaultSecret := c.SecretKey == DefaultSecretKey && (len(c.SigningKeys) == 0 || c.OIDCIssuer != "")
+
+	if c.Mode == ModeProduction {
+		if defaultPassword {
+			errs = append(errs, errors.New("TODO_PASSWORD must be changed from the default in production mode"))
+		}
+		if defaultSecret {
+			errs = append(errs, errors.New("TODO_SECRET must be changed from the default in production mode"))
+		}
+		return errs
 	}
 
-	secretKey, exists := os.LookupEnv("TODO_SECRET")
-	if !exists || secretKey == "" {
-		fmt.Println("no secret key in .env, must set for auth, will use default secret key (my_secret_key)")
-		config.SecretKey = "my_secret_key"
-	} else {
-		config.SecretKey = secretKey
+	if defaultPassword {
+		slog.Warn("using the default password (12345), set TODO_PASSWORD")
+	}
+	if defaultSecret {
+		slog.Warn("using the default secret key, set TODO_SECRET")
 	}
 
-	return config
+	return errs
 }
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadable are the fields a running server picks up on reload. The others
+// are read once at startup and need a restart.
+var reloadable = map[string]bool{
+	"Password":          true,
+	"SecretKey":         true,
+	"LogLevel":          true,
+	"AccessTokenTTL":    true,
+	"RefreshTokenTTL":   true,
+	"AllowRegistration": true,
+}
+
+// secret fields are logged as changed without their values.
+var secret = map[string]bool{
+	"Password":         true,
+	"SecretKey":        true,
+	"MetricsToken":     true,
+	"OIDCClientSecret": true,
+}
+
+// reloadDebounce collapses the burst of events an editor produces when it
+// saves a file.
+const reloadDebounce = 100 * time.Millisecond
+
+// Shared holds the configuration used by the handlers and the auth service.
+// Readers call Get on every use, so a reload applies from the next request
+// on and a request never sees half of it.
+type Shared struct {
+	current atomic.Pointer[Config]
+	level   slog.LevelVar
+}
+
+func NewShared(config *Config) *Shared {
+
+	shared := &Shared{}
+	shared.Store(config)
+	return shared
+}
+
+func (s *Shared) Get() *Config {
+	return s.current.Load()
+}
+
+func (s *Shared) Store(config *Config) {
+
+	s.current.Store(config)
+	s.level.Set(config.LogLevel)
+}
+
+// LogLevel follows LogLevel of the current configuration, for the logger.
+func (s *Shared) LogLevel() slog.Leveler {
+	return &s.level
+}
+
+// Reload loads the configuration again and swaps in the fields that are safe
+// to change. An invalid configuration is rejected as a whole and the current
+// one stays in use.
+func (s *Shared) Reload(flags *Flags) error {
+
+	loaded, err := Load(flags)
+	if err != nil {
+		return err
+	}
+
+	current := s.Get()
+	next := *current
+
+	oldValue := reflect.ValueOf(current).Elem()
+	newValue := reflect.ValueOf(loaded).Elem()
+	nextValue := reflect.ValueOf(&next).Elem()
+	changed := 0
+
+	for i := 0; i < oldValue.NumField(); i++ {
+		name := oldValue.Type().Field(i).Name
+		before, after := oldValue.Field(i).Interface(), newValue.Field(i).Interface()
+		if reflect.DeepEqual(before, after) {
+			continue
+		}
+
+		if !reloadable[name] {
+			slog.Warn("config change needs a restart, ignored", "field", name)
+			continue
+		}
+
+		nextValue.Field(i).Set(newValue.Field(i))
+		changed++
+		if secret[name] {
+			slog.Info("config changed", "field", name)
+		} else {
+			slog.Info("config changed", "field", name, "old", before, "new", after)
+		}
+	}
+
+	if changed > 0 {
+		s.Store(&next)
+	}
+	slog.Info("config reloaded", "changed", changed)
+
+	return nil
+}
+
+// Watch reloads the configuration on SIGHUP and whenever the config file is
+// written, until ctx is done. Rejected reloads are logged and the server
+// keeps the configuration it has.
+func (s *Shared) Watch(ctx context.Context, flags *Flags) error {
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var events chan fsnotify.Event
+	var watchErrors chan error
+
+	path := FilePath(flags)
+	if path != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("fsnotify.NewWatcher: function error: %w", err)
+		}
+		defer watcher.Close()
+
+		// Editors often replace the file instead of writing to it, which
+		// would drop a watch on the file itself.
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("watcher.Add: function error: %w", err)
+		}
+		events, watchErrors = watcher.Events, watcher.Errors
+	}
+
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hangup:
+			slog.Info("SIGHUP received, reloading config")
+			s.reload(flags)
+		case event := <-events:
+			if filepath.Clean(event.Name) == filepath.Clean(path) && event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				timer.Reset(reloadDebounce)
+			}
+		case <-timer.C:
+			slog.Info("config file changed, reloading config", "path", path)
+			s.reload(flags)
+		case err := <-watchErrors:
+			slog.Error("config file watch failed", "path", path, "error", err)
+		}
+	}
+}
+
+func (s *Shared) reload(flags *Flags) {
+
+	if err := s.Reload(flags); err != nil {
+		slog.Error("config reload rejected", "error", err)
+	}
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// setting is one configuration value. Its key in the config file is the
+// environment name without the TODO_ prefix in lower case, and its flag is
+// the key with dashes: TODO_ACCESS_TOKEN_TTL, access_token_ttl and
+// -access-token-ttl.
+type setting struct {
+	env   string
+	usage string
+	set   func(config *Config, value string) error
+}
+
+func (s setting) key() string {
+	return strings.ToLower(strings.TrimPrefix(s.env, "TODO_"))
+}
+
+func (s setting) flag() string {
+	return strings.ReplaceAll(s.key(), "_", "-")
+}
+
+var settings = []setting{
+	{"TODO_MODE", "development or production", oneOf(func(c *Config) *string { return &c.Mode }, ModeDevelopment, ModeProduction)},
+	{"TODO_PORT", "listen address, e.g. 7540 or 127.0.0.1:7540", setPort},
+	{"TODO_DBFILE", "path to the SQLite database", text(func(c *Config) *string { return &c.StoragePath })},
+	{"TODO_PASSWORD", "admin password or its bcrypt/argon2id hash", text(func(c *Config) *string { return &c.Password })},
+	{"TODO_SECRET", "HS256 secret for access tokens and the key of the OIDC sign-in cookie", text(func(c *Config) *string { return &c.SecretKey })},
+	{"TODO_SIGNING_KEYS", "comma-separated PEM files, the first one signs", setSigningKeys},
+	{"TODO_QUERY_TIMEOUT", "timeout of a single storage call, 0 to disable", duration(func(c *Config) *time.Duration { return &c.QueryTimeout }, true)},
+	{"TODO_IDEMPOTENCY_TTL", "how long Idempotency-Key responses are kept", duration(func(c *Config) *time.Duration { return &c.IdempotencyTTL }, false)},
+	{"TODO_ALLOW_REGISTRATION", "allow sign-up of new users", boolean(func(c *Config) *bool { return &c.AllowRegistration })},
+	{"TODO_ACCESS_TOKEN_TTL", "access token lifetime", duration(func(c *Config) *time.Duration { return &c.AccessTokenTTL }, false)},
+	{"TODO_REFRESH_TOKEN_TTL", "refresh token lifetime", duration(func(c *Config) *time.Duration { return &c.RefreshTokenTTL }, false)},
+	{"TODO_READ_TIMEOUT", "HTTP read timeout", duration(func(c *Config) *time.Duration { return &c.ReadTimeout }, false)},
+	{"TODO_WRITE_TIMEOUT", "HTTP write timeout", duration(func(c *Config) *time.Duration { return &c.WriteTimeout }, false)},
+	{"TODO_IDLE_TIMEOUT", "HTTP keep-alive timeout", duration(func(c *Config) *time.Duration { return &c.IdleTimeout }, false)},
+	{"TODO_SHUTDOWN_DELAY", "how long to keep serving after SIGTERM", duration(func(c *Config) *time.Duration { return &c.ShutdownDelay }, true)},
+	{"TODO_SHUTDOWN_TIMEOUT", "how long to wait for in-flight requests", duration(func(c *Config) *time.Duration { return &c.ShutdownTimeout }, false)},
+	{"TODO_METRICS_TOKEN", "bearer token required by /metrics", text(func(c *Config) *string { return &c.MetricsToken })},
+	{"TODO_TRACING_EXPORTER", "otlp or stdout", oneOf(func(c *Config) *string { return &c.TracingExporter }, "otlp", "stdout")},
+	{"TODO_LOG_FORMAT", "text or json", oneOf(func(c *Config) *string { return &c.LogFormat }, "text", "json")},
+	{"TODO_LOG_LEVEL", "debug, info, warn or error", setLogLevel},
+	{"TODO_OIDC_ISSUER", "OpenID Connect issuer URL", text(func(c *Config) *string { return &c.OIDCIssuer })},
+	{"TODO_OIDC_CLIENT_ID", "OpenID Connect client id", text(func(c *Config) *string { return &c.OIDCClientID })},
+	{"TODO_OIDC_CLIENT_SECRET", "OpenID Connect client secret", text(func(c *Config) *string { return &c.OIDCClientSecret })},
+	{"TODO_OIDC_REDIRECT_URL", "OpenID Connect redirect URL", text(func(c *Config) *string { return &c.OIDCRedirectURL })},
+}
+
+func text(field func(*Config) *string) func(*Config, string) error {
+	return func(config *Config, value string) error {
+		*field(config) = value
+		return nil
+	}
+}
+
+func oneOf(field func(*Config) *string, allowed ...string) func(*Config, string) error {
+	return func(config *Config, value string) error {
+		for _, option := range allowed {
+			if value == option {
+				*field(config) = value
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(allowed, ", "))
+	}
+}
+
+func duration(field func(*Config) *time.Duration, allowZero bool) func(*Config, string) error {
+	return func(config *Config, value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("not a duration such as 30s or 15m")
+		}
+		if parsed < 0 || parsed == 0 && !allowZero {
+			return fmt.Errorf("must be positive")
+		}
+		*field(config) = parsed
+		return nil
+	}
+}
+
+func boolean(field func(*Config) *bool) func(*Config, string) error {
+	return func(config *Config, value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		*field(config) = parsed
+		return nil
+	}
+}
+
+func setPort(config *Config, value string) error {
+
+	if !strings.Contains(value, ":") {
+		value = ":" + value
+	}
+
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return fmt.Errorf("not an address: %w", err)
+	}
+
+	if number, err := strconv.Atoi(port); err != nil || number < 0 || number > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+
+	config.Port = value
+	return nil
+}
+
+func setSigningKeys(config *Config, value string) error {
+
+	config.SigningKeys = nil
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			config.SigningKeys = append(config.SigningKeys, path)
+		}
+	}
+	return nil
+}
+
+func setLogLevel(config *Config, value string) error {
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return fmt.Errorf("must be debug, info, warn or error")
+	}
+
+	config.LogLevel = level
+	return nil
+}
+
+// apply sets values keyed by setting key and returns an error for every
+// unknown key or invalid value. Empty values are ignored.
+func apply(config *Config, values map[string]string, source string) []error {
+
+	known := map[string]setting{}
+	for _, s := range settings {
+		known[s.key()] = s
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		s, ok := known[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", source, key))
+			continue
+		}
+		if values[key] == "" {
+			continue
+		}
+		if err := s.set(config, values[key]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s=%q: %w", source, key, values[key], err))
+		}
+	}
+
+	return errs
+}
+
+// ReadFile reads a flat YAML (.yaml, .yml) or TOML (.toml) file keyed by
+// setting key. Lists are joined with commas.
+func ReadFile(path string) (map[string]string, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file read error: %w", err)
+	}
+
+	raw := map[string]interface{}{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: parse error: %w", path, err)
+	}
+
+	values := map[string]string{}
+	for key, value := range raw {
+		switch v := value.(type) {
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+
+	return values, nil
+}
+
+// Flags is the command-line layer. Only flags that were given override the
+// file and the environment.
+type Flags struct {
+	File string
+
+	set     *flag.FlagSet
+	strings map[string]*string
+}
+
+// RegisterFlags adds -config and a flag for every setting to set.
+func RegisterFlags(set *flag.FlagSet) *Flags {
+
+	flags := &Flags{set: set, strings: map[string]*string{}}
+	set.StringVar(&flags.File, "config", "", "config file (.yaml, .yml or .toml), TODO_CONFIG by default")
+
+	for _, s := range settings {
+		flags.strings[s.key()] = set.String(s.flag(), "", s.usage+" ("+s.env+")")
+	}
+
+	return flags
+}
+
+func (f *Flags) values() map[string]string {
+
+	values := map[string]string{}
+	f.set.Visit(func(visited *flag.Flag) {
+		key := strings.ReplaceAll(visited.Name, "-", "_")
+		if value, ok := f.strings[key]; ok {
+			values[key] = *value
+		}
+	})
+
+	return values
+}
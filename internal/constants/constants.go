@@ -1,6 +1,7 @@
 package constants
 
 const (
-	DateFormat = "20060102"
-	TasksLimit = 10
+	DateFormat    = "20060102"
+	ISODateFormat = "2006-01-02"
+	TasksLimit    = 10
 )
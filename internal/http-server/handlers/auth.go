@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/services"
+)
+
+const refreshCookie = "refresh_token"
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func NewTokenResponse(pair middlewares.TokenPair) TokenResponse {
+	return TokenResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    int64(pair.ExpiresIn / time.Second),
+	}
+}
+
+// SetRefreshCookie keeps the refresh token where page scripts cannot read it;
+// the web interface refreshes its token cookie through /api/token/refresh.
+func SetRefreshCookie(write http.ResponseWriter, pair middlewares.TokenPair, ttl time.Duration) {
+
+	http.SetCookie(write, &http.Cookie{
+		Name:     refreshCookie,
+		Value:    pair.RefreshToken,
+		Path:     "/api",
+		MaxAge:   int(ttl / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// setTokenCookie stores the access token where the web interface reads it.
+// The cookie outlives the token; the page renews it through the refresh token.
+func setTokenCookie(write http.ResponseWriter, pair middlewares.TokenPair, ttl time.Duration) {
+
+	http.SetCookie(write, &http.Cookie{
+		Name:     "token",
+		Value:    pair.AccessToken,
+		Path:     "/",
+		MaxAge:   int(ttl / time.Second),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearAuthCookies(write http.ResponseWriter) {
+
+	http.SetCookie(write, &http.Cookie{Name: "token", Path: "/", MaxAge: -1})
+	http.SetCookie(write, &http.Cookie{Name: refreshCookie, Path: "/api", MaxAge: -1, HttpOnly: true})
+}
+
+func (h *TaskHandler) RefreshToken(write http.ResponseWriter, request *http.Request) {
+
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := json.NewDecoder(request.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		services.WriteProblem(write, request, ErrInvalidJSON.Wrap(err))
+		return
+	}
+
+	fromCookie := false
+	if input.RefreshToken == "" {
+		cookie, err := request.Cookie(refreshCookie)
+		if err != nil {
+			services.WriteProblem(write, request, apperrors.Validation("validation_failed", "refresh token is required",
+				apperrors.Field("refresh_token", "required", "refresh token is required")))
+			return
+		}
+		input.RefreshToken = cookie.Value
+		fromCookie = true
+	}
+
+	pair, err := h.AuthService.RefreshTokens(request.Context(), input.RefreshToken)
+	if err != nil {
+		if fromCookie {
+			clearAuthCookies(write)
+		}
+		services.WriteProblem(write, request, fmt.Errorf("RefreshTokens: function error: %w", err))
+		return
+	}
+
+	if fromCookie {
+		SetRefreshCookie(write, pair, h.Config.Get().RefreshTokenTTL)
+		setTokenCookie(write, pair, h.Config.Get().RefreshTokenTTL)
+	}
+
+	writeJSON(write, http.StatusOK, NewTokenResponse(pair))
+}
+
+func (h *TaskHandler) SignOut(write http.ResponseWriter, request *http.Request) {
+
+	identity, _ := middlewares.IdentityFrom(request.Context())
+
+	if err := h.AuthService.SignOut(request.Context(), identity); err != nil {
+		services.WriteProblem(write, request, fmt.Errorf("SignOut: function error: %w", err))
+		return
+	}
+
+	clearAuthCookies(write)
+	write.WriteHeader(http.StatusNoContent)
+}
+
+// JWKS publishes the token verification keys so that other services can check
+// access tokens without sharing a secret.
+func (h *TaskHandler) JWKS(write http.ResponseWriter, request *http.Request) {
+
+	write.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(write, http.StatusOK, h.AuthService.JWKS())
+}
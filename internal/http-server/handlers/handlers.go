@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
+	"todo_restapi/internal/apperrors"
 	"todo_restapi/internal/config"
 	"todo_restapi/internal/constants"
 	"todo_restapi/internal/http-server/middlewares"
@@ -14,17 +17,92 @@ import (
 	"todo_restapi/internal/storage"
 )
 
+var ErrInvalidJSON = apperrors.Validation("invalid_json", "request body is not valid JSON")
+
 type TaskHandler struct {
 	Storage     *storage.Storage
-	Config      *config.Config
+	Config      *config.Shared
 	AuthService *middlewares.AuthService
 }
 
-func NewTaskHandler(storage *storage.Storage, cfg *config.Config) *TaskHandler {
+func StorageError(function string, err error) error {
+
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return apperrors.NotFound("task_not_found", "task not found").Wrap(err)
+	case errors.Is(err, storage.ErrAPIKeyNotFound):
+		return apperrors.NotFound("api_key_not_found", "api key not found").Wrap(err)
+	case errors.Is(err, storage.ErrSessionNotFound):
+		return apperrors.NotFound("session_not_found", "session not found").Wrap(err)
+	case errors.Is(err, storage.ErrUserExists):
+		return apperrors.Conflict("user_exists", "user with this login already exists").Wrap(err)
+	case errors.Is(err, storage.ErrUserNotFound):
+		return apperrors.NotFound("user_not_found", "user not found").Wrap(err)
+	case errors.Is(err, storage.ErrListNotFound):
+		return apperrors.NotFound("list_not_found", "list not found").Wrap(err)
+	case errors.Is(err, storage.ErrMemberNotFound):
+		return apperrors.NotFound("member_not_found", "list member not found").Wrap(err)
+	case errors.Is(err, storage.ErrMemberExists):
+		return apperrors.Conflict("member_exists", "user is already a member of this list").Wrap(err)
+	case errors.Is(err, storage.ErrLastOwner):
+		return apperrors.Conflict("last_owner", "list must keep at least one owner").Wrap(err)
+	case errors.Is(err, storage.ErrInvalidID):
+		return apperrors.Validation("validation_failed", "invalid task id",
+			apperrors.Field("id", "invalid", "id must be an integer")).Wrap(err)
+	default:
+		return fmt.Errorf("%s: function error: %w", function, err)
+	}
+}
+
+// CompleteTask marks the task as done in a single transaction: one-off tasks
+// are deleted, repeating ones are moved to their next date after now.
+func CompleteTask(ctx context.Context, store *storage.Storage, scope storage.TaskScope, id string, now time.Time) (models.Task, bool, error) {
+
+	var task models.Task
+	deleted := false
+
+	err := store.WithTx(ctx, func(tx *storage.Storage) error {
+
+		var err error
+		task, err = tx.GetTask(ctx, scope, id)
+		if err != nil {
+			return StorageError("GetTask", err)
+		}
+
+		if task.Repeat == "" {
+			if err := tx.DeleteTask(ctx, scope, id); err != nil {
+				return StorageError("DeleteTask", err)
+			}
+			deleted = true
+			return nil
+		}
+
+		nextDate, err := services.NextDate(now, task.Date, task.Repeat)
+		if err != nil {
+			return fmt.Errorf("NextDate error: %w", err)
+		}
+
+		task.Date = nextDate
+
+		if err := tx.EditTask(ctx, scope, task); err != nil {
+			return StorageError("EditTask", err)
+		}
+
+		task, err = tx.GetTask(ctx, scope, id)
+		if err != nil {
+			return StorageError("GetTask", err)
+		}
+		return nil
+	})
+
+	return task, deleted, err
+}
+
+func NewTaskHandler(storage *storage.Storage, cfg *config.Shared, authService *middlewares.AuthService) *TaskHandler {
 	return &TaskHandler{
 		Storage:     storage,
 		Config:      cfg,
-		AuthService: middlewares.NewAuthService(cfg),
+		AuthService: authService,
 	}
 }
 
@@ -36,13 +114,14 @@ func (h *TaskHandler) NextDate(write http.ResponseWriter, request *http.Request)
 
 	timeParse, err := time.Parse(constants.DateFormat, timeNow)
 	if err != nil {
-		http.Error(write, fmt.Sprintf("time parse error: %v", err), http.StatusInternalServerError)
+		services.WriteProblem(write, request, apperrors.Validation("validation_failed", "invalid now format",
+			apperrors.Field("now", "invalid_format", "invalid now format")).Wrap(err))
 		return
 	}
 
 	result, err := services.NextDate(timeParse, date, repeat)
 	if err != nil {
-		http.Error(write, fmt.Sprintf("NextDate: function error: %v", err), http.StatusBadRequest)
+		services.WriteProblem(write, request, err)
 		return
 	}
 
@@ -58,9 +137,9 @@ func (h *TaskHandler) GetTask(write http.ResponseWriter, request *http.Request)
 
 	id := request.FormValue("id")
 
-	task, err := h.Storage.GetTask(id)
+	task, err := h.Storage.GetTask(request.Context(), middlewares.TaskScope(request.Context()), id)
 	if err != nil {
-		services.WriteJSONError(write, http.StatusBadRequest, fmt.Sprintf("GetTask: function error: %v", err))
+		services.WriteProblem(write, request, StorageError("GetTask", err))
 		return
 	}
 
@@ -79,18 +158,18 @@ func (h *TaskHandler) AddTask(write http.ResponseWriter, request *http.Request)
 	newTask := new(models.Task)
 
 	if err := json.NewDecoder(request.Body).Decode(newTask); err != nil {
-		http.Error(write, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+		services.WriteProblem(write, request, ErrInvalidJSON.Wrap(err))
 		return
 	}
 
 	if err := services.ValidateTaskRequest(newTask, now); err != nil {
-		services.WriteJSONError(write, http.StatusBadRequest, fmt.Sprintf("ValidateTaskRequest: function error: %v", err))
+		services.WriteProblem(write, request, err)
 		return
 	}
 
-	taskID, err := h.Storage.AddTask(*newTask)
+	taskID, err := h.Storage.AddTask(request.Context(), middlewares.TaskScope(request.Context()), *newTask)
 	if err != nil {
-		http.Error(write, fmt.Sprintf("AddTask: add task error: %v", err), http.StatusInternalServerError)
+		services.WriteProblem(write, request, StorageError("AddTask", err))
 		return
 	}
 
@@ -111,17 +190,17 @@ func (h *TaskHandler) EditTask(write http.ResponseWriter, request *http.Request)
 	newTask := new(models.Task)
 
 	if err := json.NewDecoder(request.Body).Decode(newTask); err != nil {
-		http.Error(write, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+		services.WriteProblem(write, request, ErrInvalidJSON.Wrap(err))
 		return
 	}
 
 	if err := services.ValidateTaskRequest(newTask, now); err != nil {
-		services.WriteJSONError(write, http.StatusBadRequest, fmt.Sprintf("ValidateTaskRequest: function error: %v", err))
+		services.WriteProblem(write, request, err)
 		return
 	}
 
-	if err := h.Storage.EditTask(*newTask); err != nil {
-		services.WriteJSONError(write, http.StatusInternalServerError, fmt.Sprintf("EditTask: function error: %v", err))
+	if err := h.Storage.EditTask(request.Context(), middlewares.TaskScope(request.Context()), *newTask); err != nil {
+		services.WriteProblem(write, request, StorageError("EditTask", err))
 		return
 	}
 
@@ -138,8 +217,8 @@ func (h *TaskHandler) DeleteTask(write http.ResponseWriter, request *http.Reques
 
 	id := request.FormValue("id")
 
-	if err := h.Storage.DeleteTask(id); err != nil {
-		services.WriteJSONError(write, http.StatusInternalServerError, fmt.Sprintf("DeleteTask: function error: %v", err))
+	if err := h.Storage.DeleteTask(request.Context(), middlewares.TaskScope(request.Context()), id); err != nil {
+		services.WriteProblem(write, request, StorageError("DeleteTask", err))
 		return
 	}
 
@@ -155,15 +234,17 @@ func (h *TaskHandler) DeleteTask(write http.ResponseWriter, request *http.Reques
 func (h *TaskHandler) GetTasks(write http.ResponseWriter, request *http.Request) {
 
 	if request.Method != http.MethodGet {
-		http.Error(write, "invalid method", http.StatusMethodNotAllowed)
+		services.WriteProblem(write, request, apperrors.MethodNotAllowed())
+		return
 	}
 
+	scope := middlewares.TaskScope(request.Context())
 	searchQuery := request.FormValue("search")
 
 	if searchQuery != "" {
-		searchTasks, err := h.Storage.SearchTasks(searchQuery)
+		searchTasks, err := h.Storage.SearchTasks(request.Context(), scope, searchQuery)
 		if err != nil {
-			services.WriteJSONError(write, http.StatusInternalServerError, fmt.Sprintf("SearchTasks: function error: %v", err))
+			services.WriteProblem(write, request, StorageError("SearchTasks", err))
 			return
 		}
 
@@ -179,9 +260,9 @@ func (h *TaskHandler) GetTasks(write http.ResponseWriter, request *http.Request)
 		return
 	}
 
-	tasks, err := h.Storage.GetTasks()
+	tasks, err := h.Storage.GetTasks(request.Context(), scope)
 	if err != nil {
-		http.Error(write, fmt.Sprintf("GetTasks: function error: %v", err), http.StatusInternalServerError)
+		services.WriteProblem(write, request, StorageError("GetTasks", err))
 		return
 	}
 
@@ -200,38 +281,17 @@ func (h *TaskHandler) GetTasks(write http.ResponseWriter, request *http.Request)
 func (h *TaskHandler) TaskIsDone(write http.ResponseWriter, request *http.Request) {
 
 	if request.Method != http.MethodPost {
-		http.Error(write, "invalid method", http.StatusMethodNotAllowed)
+		services.WriteProblem(write, request, apperrors.MethodNotAllowed())
 		return
 	}
 
 	id := request.FormValue("id")
 
-	task, err := h.Storage.GetTask(id)
-	if err != nil {
-		services.WriteJSONError(write, http.StatusBadRequest, fmt.Sprintf("GetTask: function error: %v", err))
+	if _, _, err := CompleteTask(request.Context(), h.Storage, middlewares.TaskScope(request.Context()), id, time.Now()); err != nil {
+		services.WriteProblem(write, request, err)
 		return
 	}
 
-	if task.Repeat == "" {
-		if err := h.Storage.DeleteTask(id); err != nil {
-			services.WriteJSONError(write, http.StatusInternalServerError, fmt.Sprintf("DeleteTask: function error: %v", err))
-			return
-		}
-	} else {
-		nextDate, err := services.NextDate(time.Now(), task.Date, task.Repeat)
-		if err != nil {
-			services.WriteJSONError(write, http.StatusInternalServerError, fmt.Sprintf("NextDate error: %v", err))
-			return
-		}
-
-		task.Date = nextDate
-
-		if err := h.Storage.EditTask(task); err != nil {
-			services.WriteJSONError(write, http.StatusInternalServerError, fmt.Sprintf("EditTask error: %v", err))
-			return
-		}
-	}
-
 	write.Header().Set("Content-Type", "application/json")
 	write.WriteHeader(http.StatusOK)
 
@@ -243,42 +303,48 @@ func (h *TaskHandler) TaskIsDone(write http.ResponseWriter, request *http.Reques
 
 func (h *TaskHandler) Authentication(write http.ResponseWriter, request *http.Request) {
 
-	type authResponse struct {
-		Token string `json:"token"`
-	}
-
-	type password struct {
-		Password string `json:"password"`
+	type credentials struct {
+		Login        string `json:"login"`
+		Password     string `json:"password"`
+		OTP          string `json:"otp"`
+		RecoveryCode string `json:"recovery_code"`
 	}
 
-	pwdFromJSON := password{
+	pwdFromJSON := credentials{
 		Password: "",
 	}
 
 	if request.Method != http.MethodPost {
-		http.Error(write, "invalid method", http.StatusMethodNotAllowed)
+		services.WriteProblem(write, request, apperrors.MethodNotAllowed())
 		return
 	}
 
 	if err := json.NewDecoder(request.Body).Decode(&pwdFromJSON); err != nil {
-		http.Error(write, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
+		services.WriteProblem(write, request, ErrInvalidJSON.Wrap(err))
 		return
 	}
 
 	pwd := pwdFromJSON.Password
 
 	if pwd == "" {
-		services.WriteJSONError(write, http.StatusBadRequest, "password cannot be empty")
+		services.WriteProblem(write, request, apperrors.Validation("validation_failed", "password cannot be empty",
+			apperrors.Field("password", "required", "password cannot be empty")))
 		return
 	}
 
-	token, err := h.AuthService.GenerateJWT(pwd)
+	pair, err := h.AuthService.GenerateJWT(request.Context(), middlewares.NewClientInfo(request), middlewares.Credentials{
+		Login:        pwdFromJSON.Login,
+		Password:     pwd,
+		OTP:          pwdFromJSON.OTP,
+		RecoveryCode: pwdFromJSON.RecoveryCode,
+	})
 	if err != nil {
-		services.WriteJSONError(write, http.StatusBadRequest, fmt.Sprintf("GenerateJWT: function error: %v", err))
+		services.WriteProblem(write, request, fmt.Errorf("GenerateJWT: function error: %w", err))
 		return
 	}
 
-	response := authResponse{Token: token}
+	SetRefreshCookie(write, pair, h.Config.Get().RefreshTokenTTL)
+	response := NewTokenResponse(pair)
 
 	write.Header().Set("Content-Type", "application/json")
 	write.WriteHeader(http.StatusOK)
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todo_restapi/internal/storage"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+// CheckResult only says whether a check passed: the probe is public, so the
+// reason of a failure goes to the log instead.
+type CheckResult struct {
+	Status string `json:"status"`
+}
+
+type ReadinessResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// HealthHandler serves the liveness and readiness probes. Draining reports
+// whether graceful shutdown has started; it may be nil.
+type HealthHandler struct {
+	Storage  *storage.Storage
+	Draining func() bool
+}
+
+func NewHealthHandler(storage *storage.Storage, draining func() bool) *HealthHandler {
+	return &HealthHandler{
+		Storage:  storage,
+		Draining: draining,
+	}
+}
+
+// Liveness only tells that the process serves requests; it does not touch
+// the database, so a slow disk does not get the process restarted.
+func (h *HealthHandler) Liveness(write http.ResponseWriter, request *http.Request) {
+
+	write.Header().Set("Cache-Control", "no-store")
+	writeJSON(write, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readiness runs every check and fails if any of them does, so that the
+// instance is taken out of rotation.
+func (h *HealthHandler) Readiness(write http.ResponseWriter, request *http.Request) {
+
+	ctx, cancel := context.WithTimeout(request.Context(), healthCheckTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) error{
+		"database":   h.checkDatabase,
+		"migrations": h.checkMigrations,
+		"disk":       h.checkDisk,
+		"shutdown":   h.checkShutdown,
+	}
+
+	response := ReadinessResponse{Status: "ok", Checks: map[string]CheckResult{}}
+	status := http.StatusOK
+
+	for name, check := range checks {
+		if err := check(ctx); err != nil {
+			slog.WarnContext(request.Context(), "readiness check failed", "check", name, "error", err)
+			response.Checks[name] = CheckResult{Status: "fail"}
+			response.Status = "fail"
+			status = http.StatusServiceUnavailable
+		} else {
+			response.Checks[name] = CheckResult{Status: "ok"}
+		}
+	}
+
+	write.Header().Set("Cache-Control", "no-store")
+	writeJSON(write, status, response)
+}
+
+func (h *HealthHandler) checkDatabase(ctx context.Context) error {
+	return h.Storage.Ping(ctx)
+}
+
+func (h *HealthHandler) checkMigrations(ctx context.Context) error {
+
+	current, latest, err := h.Storage.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if current < latest {
+		return fmt.Errorf("schema version %d, want %d", current, latest)
+	}
+
+	return nil
+}
+
+func (h *HealthHandler) checkDisk(ctx context.Context) error {
+	return h.Storage.CheckWritable(ctx)
+}
+
+func (h *HealthHandler) checkShutdown(ctx context.Context) error {
+
+	if h.Draining != nil && h.Draining() {
+		return errors.New("server is shutting down")
+	}
+
+	return nil
+}
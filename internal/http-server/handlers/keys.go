@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/models"
+	"todo_restapi/internal/services"
+)
+
+const maxAPIKeyNameLength = 100
+
+type APIKeyResponse struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Key        string     `json:"key,omitempty"`
+}
+
+func newAPIKeyResponse(key models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+	}
+}
+
+func writeJSON(write http.ResponseWriter, status int, value interface{}) {
+
+	write.Header().Set("Content-Type", "application/json")
+	write.WriteHeader(status)
+
+	if err := json.NewEncoder(write).Encode(value); err != nil {
+		http.Error(write, "failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func (h *TaskHandler) GetAPIKeys(write http.ResponseWriter, request *http.Request) {
+
+	keys, err := h.Storage.GetAPIKeys(request.Context(), middlewares.UserID(request.Context()))
+	if err != nil {
+		services.WriteProblem(write, request, StorageError("GetAPIKeys", err))
+		return
+	}
+
+	response := struct {
+		Keys []APIKeyResponse `json:"keys"`
+	}{Keys: make([]APIKeyResponse, 0, len(keys))}
+
+	for _, key := range keys {
+		response.Keys = append(response.Keys, newAPIKeyResponse(key))
+	}
+
+	writeJSON(write, http.StatusOK, response)
+}
+
+func (h *TaskHandler) CreateAPIKey(write http.ResponseWriter, request *http.Request) {
+
+	var input struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+
+	if err := json.NewDecoder(request.Body).Decode(&input); err != nil {
+		services.WriteProblem(write, request, ErrInvalidJSON.Wrap(err))
+		return
+	}
+
+	var fields []apperrors.FieldError
+	if len(input.Name) > maxAPIKeyNameLength {
+		fields = append(fields, apperrors.Field("name", "too_long",
+			fmt.Sprintf("name must be at most %d bytes long", maxAPIKeyNameLength)))
+	}
+	if len(input.Scopes) == 0 {
+		fields = append(fields, apperrors.Field("scopes", "required", "at least one scope is required"))
+	}
+	for _, scope := range input.Scopes {
+		if !slices.Contains(middlewares.Scopes, scope) {
+			fields = append(fields, apperrors.Field("scopes", "unknown", fmt.Sprintf("unknown scope %q", scope)))
+		}
+	}
+	if len(fields) > 0 {
+		services.WriteProblem(write, request, apperrors.Validation("validation_failed", "invalid api key", fields...))
+		return
+	}
+
+	slices.Sort(input.Scopes)
+	input.Scopes = slices.Compact(input.Scopes)
+
+	key, plaintext, err := h.AuthService.CreateAPIKey(request.Context(), middlewares.UserID(request.Context()), input.Name, input.Scopes)
+	if err != nil {
+		services.WriteProblem(write, request, fmt.Errorf("CreateAPIKey: function error: %w", err))
+		return
+	}
+
+	response := newAPIKeyResponse(key)
+	response.Key = plaintext
+
+	write.Header().Set("Location", fmt.Sprintf("/api/keys/%d", key.ID))
+	writeJSON(write, http.StatusCreated, response)
+}
+
+func (h *TaskHandler) RevokeAPIKey(write http.ResponseWriter, request *http.Request) {
+
+	keyID, err := strconv.ParseInt(chi.URLParam(request, "id"), 10, 64)
+	if err != nil {
+		services.WriteProblem(write, request, apperrors.Validation("validation_failed", "invalid api key id",
+			apperrors.Field("id", "invalid", "id must be an integer")).Wrap(err))
+		return
+	}
+
+	if err := h.Storage.RevokeAPIKey(request.Context(), middlewares.UserID(request.Context()), keyID); err != nil {
+		services.WriteProblem(write, request, StorageError("RevokeAPIKey", err))
+		return
+	}
+
+	write.WriteHeader(http.StatusNoContent)
+}
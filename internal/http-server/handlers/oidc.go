@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/config"
+	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/oidc"
+	"todo_restapi/internal/services"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcFlowTTL     = 10 * time.Minute
+)
+
+var (
+	errOIDCDisabled     = apperrors.NotFound("oidc_disabled", "OpenID Connect sign-in is not configured")
+	errOIDCInvalidState = apperrors.Unauthorized("oidc_invalid_state", "sign-in request expired or is invalid")
+)
+
+type oidcFlow struct {
+	State     string `json:"state"`
+	Nonce     string `json:"nonce"`
+	Verifier  string `json:"verifier"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// OIDCHandler runs the authorization code flow with PKCE. A pending flow is
+// not kept on the server: state, nonce and verifier travel in a cookie
+// encrypted with a key derived from TODO_SECRET, which also binds the flow
+// to the browser that started it.
+type OIDCHandler struct {
+	Provider    *oidc.Provider
+	AuthService *middlewares.AuthService
+	Config      *config.Shared
+}
+
+func NewOIDCHandler(cfg *config.Shared, authService *middlewares.AuthService) *OIDCHandler {
+
+	handler := &OIDCHandler{
+		AuthService: authService,
+		Config:      cfg,
+	}
+
+	if current := cfg.Get(); current.OIDCIssuer != "" {
+		handler.Provider = oidc.NewProvider(current.OIDCIssuer, current.OIDCClientID, current.OIDCClientSecret, current.OIDCRedirectURL)
+	}
+
+	return handler
+}
+
+func (h *OIDCHandler) Login(write http.ResponseWriter, request *http.Request) {
+
+	if h.Provider == nil {
+		services.WriteProblem(write, request, errOIDCDisabled)
+		return
+	}
+
+	flow := oidcFlow{ExpiresAt: time.Now().Add(oidcFlowTTL).Unix()}
+	var err error
+
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		if *value, err = services.RandomToken(32); err != nil {
+			services.WriteProblem(write, request, err)
+			return
+		}
+	}
+
+	authURL, err := h.Provider.AuthCodeURL(flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		services.WriteProblem(write, request, fmt.Errorf("AuthCodeURL: function error: %w", err))
+		return
+	}
+
+	sealed, err := h.sealFlow(flow)
+	if err != nil {
+		services.WriteProblem(write, request, fmt.Errorf("sealFlow: function error: %w", err))
+		return
+	}
+
+	http.SetCookie(write, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    sealed,
+		Path:     "/api/oidc",
+		MaxAge:   int(oidcFlowTTL / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(write, request, authURL, http.StatusFound)
+}
+
+func (h *OIDCHandler) Callback(write http.ResponseWriter, request *http.Request) {
+
+	if h.Provider == nil {
+		services.WriteProblem(write, request, errOIDCDisabled)
+		return
+	}
+
+	query := request.URL.Query()
+
+	if providerError := query.Get("error"); providerError != "" {
+		services.WriteProblem(write, request, apperrors.Unauthorized("oidc_failed", "sign-in was rejected by the provider").
+			Wrap(fmt.Errorf("provider error %q: %s", providerError, query.Get("error_description"))))
+		return
+	}
+
+	cookie, err := request.Cookie(oidcStateCookie)
+	if err != nil {
+		services.WriteProblem(write, request, errOIDCInvalidState.Wrap(err))
+		return
+	}
+
+	http.SetCookie(write, &http.Cookie{Name: oidcStateCookie, Path: "/api/oidc", MaxAge: -1, HttpOnly: true})
+
+	flow, err := h.openFlow(cookie.Value)
+	if err != nil {
+		services.WriteProblem(write, request, errOIDCInvalidState.Wrap(err))
+		return
+	}
+
+	state := query.Get("state")
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(flow.State)) != 1 ||
+		time.Now().Unix() > flow.ExpiresAt {
+		services.WriteProblem(write, request, errOIDCInvalidState)
+		return
+	}
+
+	claims, err := h.Provider.Exchange(query.Get("code"), flow.Verifier, flow.Nonce)
+	if err != nil {
+		services.WriteProblem(write, request, apperrors.Unauthorized("oidc_failed", "could not verify the provider response").Wrap(err))
+		return
+	}
+
+	pair, err := h.AuthService.SignInOIDC(request.Context(), middlewares.NewClientInfo(request), claims)
+	if err != nil {
+		services.WriteProblem(write, request, fmt.Errorf("SignInOIDC: function error: %w", err))
+		return
+	}
+
+	SetRefreshCookie(write, pair, h.Config.Get().RefreshTokenTTL)
+	setTokenCookie(write, pair, h.Config.Get().RefreshTokenTTL)
+
+	http.Redirect(write, request, "/", http.StatusFound)
+}
+
+// flowCipher derives the cookie key from the current secret, so every
+// instance sharing TODO_SECRET accepts the cookie; changing the secret only
+// cancels the sign-ins in progress.
+func (h *OIDCHandler) flowCipher() (cipher.AEAD, error) {
+
+	key := sha256.Sum256([]byte("todo_restapi oidc flow\x00" + h.Config.Get().SecretKey))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (h *OIDCHandler) sealFlow(flow oidcFlow) (string, error) {
+
+	aead, err := h.flowCipher()
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := json.Marshal(flow)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+func (h *OIDCHandler) openFlow(value string) (oidcFlow, error) {
+
+	var flow oidcFlow
+
+	aead, err := h.flowCipher()
+	if err != nil {
+		return flow, err
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return flow, errors.New("malformed flow cookie")
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return flow, fmt.Errorf("flow cookie open error: %w", err)
+	}
+
+	if err := json.Unmarshal(plaintext, &flow); err != nil {
+		return flow, fmt.Errorf("flow cookie decode error: %w", err)
+	}
+
+	return flow, nil
+}
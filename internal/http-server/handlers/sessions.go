@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/models"
+	"todo_restapi/internal/services"
+)
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+func newSessionResponse(session models.Session, currentID string) SessionResponse {
+	return SessionResponse{
+		ID:         session.ID,
+		Device:     services.DeviceName(session.UserAgent),
+		IP:         session.IP,
+		UserAgent:  session.UserAgent,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		Current:    session.ID == currentID,
+	}
+}
+
+func (h *TaskHandler) GetSessions(write http.ResponseWriter, request *http.Request) {
+
+	identity, _ := middlewares.IdentityFrom(request.Context())
+
+	sessions, err := h.Storage.GetSessions(request.Context(), identity.UserID)
+	if err != nil {
+		services.WriteProblem(write, request, StorageError("GetSessions", err))
+		return
+	}
+
+	response := struct {
+		Sessions []SessionResponse `json:"sessions"`
+	}{Sessions: make([]SessionResponse, 0, len(sessions))}
+
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, newSessionResponse(session, identity.SessionID))
+	}
+
+	writeJSON(write, http.StatusOK, response)
+}
+
+// RevokeSession ends one of the caller's sessions. Its refresh token stops
+// working and so do the access tokens already issued for it.
+func (h *TaskHandler) RevokeSession(write http.ResponseWriter, request *http.Request) {
+
+	identity, _ := middlewares.IdentityFrom(request.Context())
+	sessionID := chi.URLParam(request, "id")
+
+	if err := h.Storage.RevokeSession(request.Context(), identity.UserID, sessionID); err != nil {
+		services.WriteProblem(write, request, StorageError("RevokeSession", err))
+		return
+	}
+
+	if sessionID == identity.SessionID {
+		clearAuthCookies(write)
+	}
+
+	write.WriteHeader(http.StatusNoContent)
+}
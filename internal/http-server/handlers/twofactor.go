@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/services"
+)
+
+type totpCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func (h *TaskHandler) GetTOTPStatus(write http.ResponseWriter, request *http.Request) {
+
+	enabled, codesLeft, err := h.AuthService.TOTPStatus(request.Context(), middlewares.UserID(request.Context()))
+	if err != nil {
+		services.WriteProblem(write, request, fmt.Errorf("TOTPStatus: function error: %w", err))
+		return
+	}
+
+	writeJSON(write, http.StatusOK, map[string]interface{}{
+		"enabled":             enabled,
+		"recovery_codes_left": codesLeft,
+	})
+}
+
+func (h *TaskHandler) EnrollTOTP(write http.ResponseWriter, request *http.Request) {
+
+	enrollment, err := h.AuthService.EnrollTOTP(request.Context(), middlewares.UserID(request.Context()))
+	if err != nil {
+		services.WriteProblem(write, request, fmt.Errorf("EnrollTOTP: function error: %w", err))
+		return
+	}
+
+	writeJSON(write, http.StatusOK, map[string]string{
+		"secret": enrollment.Secret,
+		"uri":    enrollment.URI,
+	})
+}
+
+func (h *TaskHandler) VerifyTOTP(write http.ResponseWriter, request *http.Request) {
+
+	var input totpCodeRequest
+
+	if err := json.NewDecoder(request.Body).Decode(&input); err != nil {
+		services.WriteProblem(write, request, ErrInvalidJSON.Wrap(err))
+		return
+	}
+
+	codes, err := h.AuthService.ConfirmTOTP(request.Context(), middlewares.NewClientInfo(request),
+		middlewares.UserID(request.Context()), input.Code)
+	if err != nil {
+		services.WriteProblem(write, request, fmt.Errorf("ConfirmTOTP: function error: %w", err))
+		return
+	}
+
+	writeJSON(write, http.StatusOK, map[string][]string{"recovery_codes": codes})
+}
+
+func (h *TaskHandler) DisableTOTP(write http.ResponseWriter, request *http.Request) {
+
+	var input totpCodeRequest
+
+	if err := json.NewDecoder(request.Body).Decode(&input); err != nil {
+		services.WriteProblem(write, request, ErrInvalidJSON.Wrap(err))
+		return
+	}
+
+	credentials := middlewares.Credentials{OTP: input.Code, RecoveryCode: input.RecoveryCode}
+	err := h.AuthService.DisableTOTP(request.Context(), middlewares.NewClientInfo(request),
+		middlewares.UserID(request.Context()), credentials)
+	if err != nil {
+		services.WriteProblem(write, request, fmt.Errorf("DisableTOTP: function error: %w", err))
+		return
+	}
+
+	write.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,181 @@
+package v1
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/constants"
+	"todo_restapi/internal/models"
+)
+
+type Task struct {
+	ID        int64     `json:"id"`
+	Date      string    `json:"date"`
+	Title     string    `json:"title"`
+	Comment   string    `json:"comment"`
+	Repeat    string    `json:"repeat"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TaskInput struct {
+	Date    string `json:"date"`
+	Title   string `json:"title"`
+	Comment string `json:"comment"`
+	Repeat  string `json:"repeat"`
+}
+
+type TaskList struct {
+	Tasks []Task `json:"tasks"`
+}
+
+type NextDateResponse struct {
+	Date string `json:"date"`
+}
+
+type SignInRequest struct {
+	Login        string `json:"login"`
+	Password     string `json:"password"`
+	OTP          string `json:"otp"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type SignUpRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+type User struct {
+	ID        int64     `json:"id"`
+	Login     string    `json:"login"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newUser(user models.User) User {
+	return User{ID: user.ID, Login: user.Login, CreatedAt: user.CreatedAt}
+}
+
+type List struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ListInput struct {
+	Title string `json:"title"`
+}
+
+type ListCollection struct {
+	Lists []List `json:"lists"`
+}
+
+type Member struct {
+	UserID    int64     `json:"user_id"`
+	Login     string    `json:"login"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MemberInput struct {
+	Login string `json:"login"`
+	Role  string `json:"role"`
+}
+
+type RoleInput struct {
+	Role string `json:"role"`
+}
+
+type MemberList struct {
+	Members []Member `json:"members"`
+}
+
+func newList(list models.List) List {
+	return List{ID: list.ID, Title: list.Title, Role: string(list.Role), CreatedAt: list.CreatedAt}
+}
+
+func newMember(member models.ListMember) Member {
+	return Member{UserID: member.UserID, Login: member.Login, Role: string(member.Role), CreatedAt: member.CreatedAt}
+}
+
+func toISODate(date string) (string, error) {
+
+	parsed, err := time.Parse(constants.DateFormat, date)
+	if err != nil {
+		return "", err
+	}
+
+	return parsed.Format(constants.ISODateFormat), nil
+}
+
+// fromISODate converts a YYYY-MM-DD date to the storage format. Empty dates
+// are passed through so that validation can default them to today.
+func fromISODate(field string, date string) (string, error) {
+
+	if date == "" {
+		return "", nil
+	}
+
+	parsed, err := time.Parse(constants.ISODateFormat, date)
+	if err != nil {
+		return "", apperrors.Validation("validation_failed", "invalid "+field+" format",
+			apperrors.Field(field, "invalid_format", field+" must be in YYYY-MM-DD format")).Wrap(err)
+	}
+
+	return parsed.Format(constants.DateFormat), nil
+}
+
+func newTask(task models.Task) (Task, error) {
+
+	id, err := strconv.ParseInt(task.ID, 10, 64)
+	if err != nil {
+		return Task{}, fmt.Errorf("task id %q: %w", task.ID, err)
+	}
+
+	date, err := toISODate(task.Date)
+	if err != nil {
+		return Task{}, fmt.Errorf("task %d date %q: %w", id, task.Date, err)
+	}
+
+	return Task{
+		ID:        id,
+		Date:      date,
+		Title:     task.Title,
+		Comment:   task.Comment,
+		Repeat:    task.Repeat,
+		CreatedAt: task.CreatedAt,
+		UpdatedAt: task.UpdatedAt,
+	}, nil
+}
+
+func newTaskList(tasks []models.Task) (TaskList, error) {
+
+	output := TaskList{Tasks: make([]Task, 0, len(tasks))}
+
+	for _, task := range tasks {
+		converted, err := newTask(task)
+		if err != nil {
+			return output, err
+		}
+		output.Tasks = append(output.Tasks, converted)
+	}
+
+	return output, nil
+}
+
+func (input TaskInput) toModel() (models.Task, error) {
+
+	date, err := fromISODate("date", input.Date)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	return models.Task{
+		Date:    date,
+		Title:   input.Title,
+		Comment: input.Comment,
+		Repeat:  input.Repeat,
+	}, nil
+}
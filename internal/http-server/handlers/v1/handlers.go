@@ -0,0 +1,341 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/config"
+	"todo_restapi/internal/constants"
+	"todo_restapi/internal/http-server/handlers"
+	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/models"
+	"todo_restapi/internal/services"
+	"todo_restapi/internal/storage"
+)
+
+var loginPattern = regexp.MustCompile(`^[A-Za-z0-9_.@-]{3,64}$`)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+type Handler struct {
+	Storage     *storage.Storage
+	Config      *config.Shared
+	AuthService *middlewares.AuthService
+}
+
+func NewHandler(storage *storage.Storage, cfg *config.Shared, authService *middlewares.AuthService) *Handler {
+	return &Handler{
+		Storage:     storage,
+		Config:      cfg,
+		AuthService: authService,
+	}
+}
+
+func writeJSON(write http.ResponseWriter, statusCode int, response interface{}) {
+
+	write.Header().Set("Content-Type", "application/json")
+	write.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(write).Encode(response); err != nil {
+		slog.Error("failed to encode response", "error", err)
+	}
+}
+
+func (h *Handler) writeTask(write http.ResponseWriter, request *http.Request, statusCode int, task models.Task) {
+
+	response, err := newTask(task)
+	if err != nil {
+		services.WriteProblem(write, request, err)
+		return
+	}
+
+	writeJSON(write, statusCode, response)
+}
+
+func (h *Handler) NextDate(write http.ResponseWriter, request *http.Request) {
+
+	now, err := fromISODate("now", request.FormValue("now"))
+	if err != nil {
+		services.WriteProblem(write, request, err)
+		return
+	}
+
+	date, err := fromISODate("date", request.FormValue("date"))
+	if err != nil {
+		services.WriteProblem(write, request, err)
+		return
+	}
+
+	timeNow, err := time.Parse(constants.DateFormat, now)
+	if err != nil {
+		services.WriteProblem(write, request, apperrors.Validation("validation_failed", "now is required",
+			apperrors.Field("now", "required", "now is required")).Wrap(err))
+		return
+	}
+
+	result, err := services.NextDate(timeNow, date, request.FormValue("repeat"))
+	if err != nil {
+		services.WriteProblem(write, request, err)
+		return
+	}
+
+	isoDate, err := toISODate(result)
+	if err != nil {
+		services.WriteProblem(write, request, fmt.Errorf("toISODate: function error: %w", err))
+		return
+	}
+
+	writeJSON(write, http.StatusOK, NextDateResponse{Date: isoDate})
+}
+
+func (h *Handler) SignIn(write http.ResponseWriter, request *http.Request) {
+
+	var input SignInRequest
+
+	if err := json.NewDecoder(request.Body).Decode(&input); err != nil {
+		services.WriteProblem(write, request, handlers.ErrInvalidJSON.Wrap(err))
+		return
+	}
+
+	if input.Password == "" {
+		services.WriteProblem(write, request, apperrors.Validation("validation_failed", "password cannot be empty",
+			apperrors.Field("password", "required", "password cannot be empty")))
+		return
+	}
+
+	pair, err := h.AuthService.GenerateJWT(request.Context(), middlewares.NewClientInfo(request), middlewares.Credentials{
+		Login:        input.Login,
+		Password:     input.Password,
+		OTP:          input.OTP,
+		RecoveryCode: input.RecoveryCode,
+	})
+	if err != nil {
+		services.WriteProblem(write, request, fmt.Errorf("GenerateJWT: function error: %w", err))
+		return
+	}
+
+	handlers.SetRefreshCookie(write, pair, h.Config.Get().RefreshTokenTTL)
+	writeJSON(write, http.StatusOK, handlers.NewTokenResponse(pair))
+}
+
+func (h *Handler) SignUp(write http.ResponseWriter, request *http.Request) {
+
+	if !h.Config.Get().AllowRegistration {
+		services.WriteProblem(write, request, apperrors.Forbidden("registration_disabled", "registration is disabled"))
+		return
+	}
+
+	var input SignUpRequest
+
+	if err := json.NewDecoder(request.Body).Decode(&input); err != nil {
+		services.WriteProblem(write, request, handlers.ErrInvalidJSON.Wrap(err))
+		return
+	}
+
+	var fields []apperrors.FieldError
+	if !loginPattern.MatchString(input.Login) {
+		fields = append(fields, apperrors.Field("login", "invalid",
+			"login must be 3 to 64 letters, digits or ._@- characters"))
+	}
+	if len(input.Password) < minPasswordLength || len(input.Password) > maxPasswordLength {
+		fields = append(fields, apperrors.Field("password", "invalid_length",
+			fmt.Sprintf("password must be %d to %d bytes long", minPasswordLength, maxPasswordLength)))
+	}
+	if len(fields) > 0 {
+		services.WriteProblem(write, request, apperrors.Validation("validation_failed", "invalid registration data", fields...))
+		return
+	}
+
+	passwordHash, err := services.HashPassword(input.Password)
+	if err != nil {
+		services.WriteProblem(write, request, fmt.Errorf("HashPassword: function error: %w", err))
+		return
+	}
+
+	userID, err := h.Storage.CreateUser(request.Context(), input.Login, passwordHash)
+	if err != nil {
+		services.WriteProblem(write, request, handlers.StorageError("CreateUser", err))
+		return
+	}
+
+	user, err := h.Storage.GetUser(request.Context(), userID)
+	if err != nil {
+		services.WriteProblem(write, request, handlers.StorageError("GetUser", err))
+		return
+	}
+
+	writeJSON(write, http.StatusCreated, newUser(user))
+}
+
+func (h *Handler) ListTasks(write http.ResponseWriter, request *http.Request) {
+
+	var tasks []models.Task
+	var err error
+	scope := middlewares.TaskScope(request.Context())
+
+	if search := request.FormValue("search"); search != "" {
+		tasks, err = h.Storage.SearchTasks(request.Context(), scope, search)
+		if err != nil {
+			services.WriteProblem(write, request, handlers.StorageError("SearchTasks", err))
+			return
+		}
+	} else {
+		tasks, err = h.Storage.GetTasks(request.Context(), scope)
+		if err != nil {
+			services.WriteProblem(write, request, handlers.StorageError("GetTasks", err))
+			return
+		}
+	}
+
+	response, err := newTaskList(tasks)
+	if err != nil {
+		services.WriteProblem(write, request, err)
+		return
+	}
+
+	writeJSON(write, http.StatusOK, response)
+}
+
+func (h *Handler) CreateTask(write http.ResponseWriter, request *http.Request) {
+
+	task, ok := h.decodeTask(write, request)
+	if !ok {
+		return
+	}
+
+	scope := middlewares.TaskScope(request.Context())
+
+	// Like UpdateTask, the task is read back in the same transaction, so a
+	// concurrent delete cannot turn a stored task into an error response.
+	var taskID int64
+	var created models.Task
+	err := h.Storage.WithTx(request.Context(), func(tx *storage.Storage) error {
+
+		var err error
+		taskID, err = tx.AddTask(request.Context(), scope, task)
+		if err != nil {
+			return handlers.StorageError("AddTask", err)
+		}
+
+		created, err = tx.GetTask(request.Context(), scope, strconv.FormatInt(taskID, 10))
+		if err != nil {
+			return handlers.StorageError("GetTask", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		services.WriteProblem(write, request, err)
+		return
+	}
+
+	write.Header().Set("Location", fmt.Sprintf("%s/%d", strings.TrimSuffix(request.URL.Path, "/"), taskID))
+	h.writeTask(write, request, http.StatusCreated, created)
+}
+
+func (h *Handler) GetTask(write http.ResponseWriter, request *http.Request) {
+
+	task, err := h.Storage.GetTask(request.Context(), middlewares.TaskScope(request.Context()), chi.URLParam(request, "id"))
+	if err != nil {
+		services.WriteProblem(write, request, handlers.StorageError("GetTask", err))
+		return
+	}
+
+	h.writeTask(write, request, http.StatusOK, task)
+}
+
+func (h *Handler) UpdateTask(write http.ResponseWriter, request *http.Request) {
+
+	task, ok := h.decodeTask(write, request)
+	if !ok {
+		return
+	}
+
+	scope := middlewares.TaskScope(request.Context())
+	task.ID = chi.URLParam(request, "id")
+
+	// The task is read back in the same transaction, so the response shows
+	// this edit and not a concurrent one.
+	var updated models.Task
+	err := h.Storage.WithTx(request.Context(), func(tx *storage.Storage) error {
+
+		if err := tx.EditTask(request.Context(), scope, task); err != nil {
+			return handlers.StorageError("EditTask", err)
+		}
+
+		var err error
+		updated, err = tx.GetTask(request.Context(), scope, task.ID)
+		if err != nil {
+			return handlers.StorageError("GetTask", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		services.WriteProblem(write, request, err)
+		return
+	}
+
+	h.writeTask(write, request, http.StatusOK, updated)
+}
+
+func (h *Handler) DeleteTask(write http.ResponseWriter, request *http.Request) {
+
+	if err := h.Storage.DeleteTask(request.Context(), middlewares.TaskScope(request.Context()), chi.URLParam(request, "id")); err != nil {
+		services.WriteProblem(write, request, handlers.StorageError("DeleteTask", err))
+		return
+	}
+
+	write.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) CompleteTask(write http.ResponseWriter, request *http.Request) {
+
+	task, deleted, err := handlers.CompleteTask(request.Context(), h.Storage, middlewares.TaskScope(request.Context()),
+		chi.URLParam(request, "id"), time.Now())
+	if err != nil {
+		services.WriteProblem(write, request, err)
+		return
+	}
+
+	if deleted {
+		write.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.writeTask(write, request, http.StatusOK, task)
+}
+
+func (h *Handler) decodeTask(write http.ResponseWriter, request *http.Request) (models.Task, bool) {
+
+	var input TaskInput
+
+	if err := json.NewDecoder(request.Body).Decode(&input); err != nil {
+		services.WriteProblem(write, request, handlers.ErrInvalidJSON.Wrap(err))
+		return models.Task{}, false
+	}
+
+	task, err := input.toModel()
+	if err != nil {
+		services.WriteProblem(write, request, err)
+		return models.Task{}, false
+	}
+
+	if err := services.ValidateTaskRequest(&task, time.Now().Format(constants.DateFormat)); err != nil {
+		services.WriteProblem(write, request, err)
+		return models.Task{}, false
+	}
+
+	return task, true
+}
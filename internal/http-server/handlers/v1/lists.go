@@ -0,0 +1,245 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/http-server/handlers"
+	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/models"
+	"todo_restapi/internal/services"
+	"todo_restapi/internal/storage"
+)
+
+const maxListTitleLength = 256
+
+var errInvalidRole = apperrors.Validation("validation_failed", "invalid role",
+	apperrors.Field("role", "invalid", "role must be owner, editor or viewer"))
+
+func (h *Handler) ListLists(write http.ResponseWriter, request *http.Request) {
+
+	lists, err := h.Storage.GetLists(request.Context(), middlewares.UserID(request.Context()))
+	if err != nil {
+		services.WriteProblem(write, request, handlers.StorageError("GetLists", err))
+		return
+	}
+
+	response := ListCollection{Lists: make([]List, 0, len(lists))}
+	for _, list := range lists {
+		response.Lists = append(response.Lists, newList(list))
+	}
+
+	writeJSON(write, http.StatusOK, response)
+}
+
+func (h *Handler) CreateList(write http.ResponseWriter, request *http.Request) {
+
+	var input ListInput
+
+	if err := json.NewDecoder(request.Body).Decode(&input); err != nil {
+		services.WriteProblem(write, request, handlers.ErrInvalidJSON.Wrap(err))
+		return
+	}
+
+	title := strings.TrimSpace(input.Title)
+	if title == "" || len(title) > maxListTitleLength {
+		message := fmt.Sprintf("title must be 1 to %d bytes long", maxListTitleLength)
+		services.WriteProblem(write, request, apperrors.Validation("validation_failed", message,
+			apperrors.Field("title", "invalid_length", message)))
+		return
+	}
+
+	userID := middlewares.UserID(request.Context())
+
+	var listID int64
+	var list models.List
+	err := h.Storage.WithTx(request.Context(), func(tx *storage.Storage) error {
+
+		var err error
+		listID, err = tx.CreateList(request.Context(), userID, title)
+		if err != nil {
+			return handlers.StorageError("CreateList", err)
+		}
+
+		list, err = tx.GetList(request.Context(), userID, listID)
+		if err != nil {
+			return handlers.StorageError("GetList", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		services.WriteProblem(write, request, err)
+		return
+	}
+
+	write.Header().Set("Location", fmt.Sprintf("/api/v1/lists/%d", listID))
+	writeJSON(write, http.StatusCreated, newList(list))
+}
+
+func (h *Handler) GetList(write http.ResponseWriter, request *http.Request) {
+
+	access, _ := middlewares.ListAccessFrom(request.Context())
+
+	list, err := h.Storage.GetList(request.Context(), middlewares.UserID(request.Context()), access.ListID)
+	if err != nil {
+		services.WriteProblem(write, request, handlers.StorageError("GetList", err))
+		return
+	}
+
+	writeJSON(write, http.StatusOK, newList(list))
+}
+
+func (h *Handler) DeleteList(write http.ResponseWriter, request *http.Request) {
+
+	access, _ := middlewares.ListAccessFrom(request.Context())
+
+	if err := h.Storage.DeleteList(request.Context(), access.ListID); err != nil {
+		services.WriteProblem(write, request, handlers.StorageError("DeleteList", err))
+		return
+	}
+
+	write.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ListMembers(write http.ResponseWriter, request *http.Request) {
+
+	access, _ := middlewares.ListAccessFrom(request.Context())
+
+	members, err := h.Storage.GetListMembers(request.Context(), access.ListID)
+	if err != nil {
+		services.WriteProblem(write, request, handlers.StorageError("GetListMembers", err))
+		return
+	}
+
+	response := MemberList{Members: make([]Member, 0, len(members))}
+	for _, member := range members {
+		response.Members = append(response.Members, newMember(member))
+	}
+
+	writeJSON(write, http.StatusOK, response)
+}
+
+// AddMember invites an existing user by login. The role defaults to viewer.
+func (h *Handler) AddMember(write http.ResponseWriter, request *http.Request) {
+
+	var input MemberInput
+
+	if err := json.NewDecoder(request.Body).Decode(&input); err != nil {
+		services.WriteProblem(write, request, handlers.ErrInvalidJSON.Wrap(err))
+		return
+	}
+
+	role := models.Role(input.Role)
+	if role == "" {
+		role = models.RoleViewer
+	}
+	if !role.Valid() {
+		services.WriteProblem(write, request, errInvalidRole)
+		return
+	}
+
+	access, _ := middlewares.ListAccessFrom(request.Context())
+
+	user, err := h.Storage.GetUserByLogin(request.Context(), input.Login)
+	if err != nil {
+		services.WriteProblem(write, request, handlers.StorageError("GetUserByLogin", err))
+		return
+	}
+
+	if err := h.Storage.AddListMember(request.Context(), access.ListID, user.ID, role); err != nil {
+		services.WriteProblem(write, request, handlers.StorageError("AddListMember", err))
+		return
+	}
+
+	h.writeMember(write, request, http.StatusCreated, access.ListID, user.ID)
+}
+
+func (h *Handler) UpdateMember(write http.ResponseWriter, request *http.Request) {
+
+	userID, ok := memberID(write, request)
+	if !ok {
+		return
+	}
+
+	var input RoleInput
+
+	if err := json.NewDecoder(request.Body).Decode(&input); err != nil {
+		services.WriteProblem(write, request, handlers.ErrInvalidJSON.Wrap(err))
+		return
+	}
+
+	role := models.Role(input.Role)
+	if !role.Valid() {
+		services.WriteProblem(write, request, errInvalidRole)
+		return
+	}
+
+	access, _ := middlewares.ListAccessFrom(request.Context())
+
+	if err := h.Storage.SetListMemberRole(request.Context(), access.ListID, userID, role); err != nil {
+		services.WriteProblem(write, request, handlers.StorageError("SetListMemberRole", err))
+		return
+	}
+
+	h.writeMember(write, request, http.StatusOK, access.ListID, userID)
+}
+
+// RemoveMember lets owners remove anyone and every member leave the list.
+func (h *Handler) RemoveMember(write http.ResponseWriter, request *http.Request) {
+
+	userID, ok := memberID(write, request)
+	if !ok {
+		return
+	}
+
+	access, _ := middlewares.ListAccessFrom(request.Context())
+
+	if userID != middlewares.UserID(request.Context()) && !access.Role.Allows(models.RoleOwner) {
+		services.WriteProblem(write, request,
+			apperrors.Forbidden("insufficient_role", "this action requires the owner role"))
+		return
+	}
+
+	if err := h.Storage.RemoveListMember(request.Context(), access.ListID, userID); err != nil {
+		services.WriteProblem(write, request, handlers.StorageError("RemoveListMember", err))
+		return
+	}
+
+	write.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) writeMember(write http.ResponseWriter, request *http.Request, statusCode int, listID int64, userID int64) {
+
+	members, err := h.Storage.GetListMembers(request.Context(), listID)
+	if err != nil {
+		services.WriteProblem(write, request, handlers.StorageError("GetListMembers", err))
+		return
+	}
+
+	for _, member := range members {
+		if member.UserID == userID {
+			writeJSON(write, statusCode, newMember(member))
+			return
+		}
+	}
+
+	services.WriteProblem(write, request, handlers.StorageError("GetListMembers", storage.ErrMemberNotFound))
+}
+
+func memberID(write http.ResponseWriter, request *http.Request) (int64, bool) {
+
+	userID, err := strconv.ParseInt(chi.URLParam(request, "userID"), 10, 64)
+	if err != nil {
+		services.WriteProblem(write, request, apperrors.Validation("validation_failed", "invalid user id",
+			apperrors.Field("userID", "invalid", "user id must be an integer")).Wrap(err))
+		return 0, false
+	}
+
+	return userID, true
+}
@@ -0,0 +1,115 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/models"
+	"todo_restapi/internal/services"
+	"todo_restapi/internal/storage"
+)
+
+const APIKeyPrefix = "todo_"
+
+const (
+	ScopeTasksRead  = "tasks:read"
+	ScopeTasksWrite = "tasks:write"
+)
+
+var Scopes = []string{ScopeTasksRead, ScopeTasksWrite}
+
+var errSessionRequired = apperrors.Forbidden("session_required", "this action requires signing in, not an API key")
+
+// CreateAPIKey returns the stored key together with its plaintext value, which
+// is shown to the user once and kept only as a hash.
+func (a *AuthService) CreateAPIKey(ctx context.Context, userID int64, name string, scopes []string) (models.APIKey, string, error) {
+
+	secret, err := services.RandomToken(32)
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+	plaintext := APIKeyPrefix + secret
+
+	key := models.APIKey{
+		UserID: userID,
+		Name:   name,
+		Prefix: plaintext[:len(APIKeyPrefix)+6],
+		Scopes: scopes,
+	}
+
+	keyID, err := a.Storage.AddAPIKey(ctx, key, services.HashToken(plaintext))
+	if err != nil {
+		return models.APIKey{}, "", fmt.Errorf("AddAPIKey: function error: %w", err)
+	}
+
+	key, err = a.Storage.GetAPIKey(ctx, userID, keyID)
+	if err != nil {
+		return models.APIKey{}, "", fmt.Errorf("GetAPIKey: function error: %w", err)
+	}
+
+	return key, plaintext, nil
+}
+
+func (a *AuthService) ValidateAPIKey(ctx context.Context, plaintext string) (Identity, error) {
+
+	key, err := a.Storage.GetAPIKeyByHash(ctx, services.HashToken(plaintext))
+	if errors.Is(err, storage.ErrAPIKeyNotFound) {
+		return Identity{}, errors.New("unknown or revoked api key")
+	} else if err != nil {
+		return Identity{}, apperrors.Internal(fmt.Errorf("GetAPIKeyByHash: function error: %w", err))
+	}
+
+	if err := a.Storage.TouchAPIKey(ctx, key.ID, time.Now()); err != nil {
+		slog.ErrorContext(ctx, "TouchAPIKey: function error", "error", err)
+	}
+
+	return Identity{UserID: key.UserID, APIKeyID: key.ID, Scopes: key.Scopes}, nil
+}
+
+// HasScope reports whether the caller may act within scope. Signed-in users
+// are not limited; API keys only get the scopes they were created with.
+func (i Identity) HasScope(scope string) bool {
+	return i.APIKeyID == 0 || slices.Contains(i.Scopes, scope)
+}
+
+// RequireScope checks readScope for safe methods and writeScope for
+// everything else.
+func RequireScope(readScope string, writeScope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+
+			scope := writeScope
+			if request.Method == http.MethodGet || request.Method == http.MethodHead {
+				scope = readScope
+			}
+
+			identity, _ := IdentityFrom(request.Context())
+			if !identity.HasScope(scope) {
+				services.WriteProblem(write, request,
+					apperrors.Forbidden("insufficient_scope", fmt.Sprintf("api key lacks the %s scope", scope)))
+				return
+			}
+			next.ServeHTTP(write, request)
+		})
+	}
+}
+
+// RequireSession keeps API keys away from account management, so a leaked key
+// cannot mint further keys or end the owner's sessions.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+
+		identity, _ := IdentityFrom(request.Context())
+		if identity.APIKeyID != 0 {
+			services.WriteProblem(write, request, errSessionRequired)
+			return
+		}
+		next.ServeHTTP(write, request)
+	})
+}
@@ -1,18 +1,79 @@
 package middlewares
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"time"
+
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/metrics"
+	"todo_restapi/internal/services"
+	"todo_restapi/internal/storage"
 )
 
+type contextKey string
+
+const identityKey contextKey = "identity"
+
+// Identity describes the caller of an authenticated request. SessionID is the
+// refresh token family the access token belongs to; requests made with an API
+// key carry APIKeyID and its Scopes instead.
+type Identity struct {
+	UserID    int64
+	TokenID   string
+	SessionID string
+	ExpiresAt time.Time
+
+	APIKeyID int64
+	Scopes   []string
+}
+
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey, identity)
+}
+
+func IdentityFrom(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey).(Identity)
+	return identity, ok
+}
+
+// UserID returns the authenticated user set by Auth, or 0 outside of it.
+func UserID(ctx context.Context) int64 {
+	identity, _ := IdentityFrom(ctx)
+	return identity.UserID
+}
+
 func Auth(authService *AuthService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
 
-			if err := authService.ValidateJWT(request); err != nil {
-				http.Error(write, "authentication required", http.StatusUnauthorized)
+			identity, err := authService.Authenticate(request)
+			if err != nil {
+				var appErr *apperrors.Error
+				if !errors.As(err, &appErr) {
+					if errors.Is(err, errTokenNotFound) {
+						metrics.AuthFailure("missing_token")
+					} else {
+						metrics.AuthFailure("invalid_token")
+					}
+					err = apperrors.Unauthorized("authentication_required", "authentication required").Wrap(err)
+				}
+				services.WriteProblem(write, request, err)
 				return
 			}
-			next.ServeHTTP(write, request)
+			next.ServeHTTP(write, request.WithContext(WithIdentity(request.Context(), identity)))
 		})
 	}
 }
+
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+
+		if UserID(request.Context()) != storage.AdminUserID {
+			services.WriteProblem(write, request, apperrors.Forbidden("admin_required", "administrator access required"))
+			return
+		}
+		next.ServeHTTP(write, request)
+	})
+}
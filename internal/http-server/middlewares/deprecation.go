@@ -0,0 +1,20 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Deprecated marks responses of legacy routes as deprecated and points
+// clients at the successor API.
+func Deprecated(successor string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+
+			write.Header().Set("Deprecation", "true")
+			write.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+
+			next.ServeHTTP(write, request)
+		})
+	}
+}
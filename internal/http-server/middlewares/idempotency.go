@@ -0,0 +1,132 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/services"
+	"todo_restapi/internal/storage"
+)
+
+const (
+	maxIdempotencyKeyLength = 255
+
+	// idempotencyWriteTimeout bounds recording the outcome of a request,
+	// which must happen even when the client is gone.
+	idempotencyWriteTimeout = 5 * time.Second
+)
+
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.statusCode == 0 {
+		r.statusCode = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func Idempotency(store *storage.Storage, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+
+			key := request.Header.Get("Idempotency-Key")
+			if key == "" {
+				next.ServeHTTP(write, request)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				message := fmt.Sprintf("Idempotency-Key must not exceed %d characters", maxIdempotencyKeyLength)
+				services.WriteProblem(write, request, apperrors.Validation("validation_failed", message,
+					apperrors.Field("Idempotency-Key", "too_long", message)))
+				return
+			}
+
+			body, err := io.ReadAll(request.Body)
+			if err != nil {
+				services.WriteProblem(write, request,
+					apperrors.Validation("invalid_body", "failed to read request body").Wrap(err))
+				return
+			}
+			request.Body = io.NopCloser(bytes.NewReader(body))
+
+			hash := sha256.New()
+			hash.Write([]byte(request.Method + "\n" + request.URL.RequestURI() + "\n"))
+			hash.Write(body)
+			requestHash := hex.EncodeToString(hash.Sum(nil))
+
+			// Keys are only unique per user, so two users may pick the same one.
+			storageKey := fmt.Sprintf("%d:%s", UserID(request.Context()), key)
+
+			record, reserved, err := store.ReserveIdempotencyKey(request.Context(), storageKey, requestHash, ttl)
+			if err != nil {
+				services.WriteProblem(write, request, fmt.Errorf("ReserveIdempotencyKey: function error: %w", err))
+				return
+			}
+
+			if !reserved {
+				switch {
+				case record.RequestHash != requestHash:
+					services.WriteProblem(write, request, apperrors.Unprocessable("idempotency_key_reused",
+						"Idempotency-Key was already used with a different request"))
+				case record.StatusCode == 0:
+					services.WriteProblem(write, request, apperrors.Conflict("idempotency_key_in_progress",
+						"a request with this Idempotency-Key is still being processed"))
+				default:
+					if record.ContentType != "" {
+						write.Header().Set("Content-Type", record.ContentType)
+					}
+					write.Header().Set("Idempotent-Replayed", "true")
+					write.WriteHeader(record.StatusCode)
+					if _, err := write.Write(record.Body); err != nil {
+						http.Error(write, "failed to write response", http.StatusInternalServerError)
+					}
+				}
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: write}
+			next.ServeHTTP(recorder, request)
+
+			// A disconnected client or a timed out handler must not leave the
+			// key reserved, or every retry would get 409 until it expires.
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(request.Context()), idempotencyWriteTimeout)
+			defer cancel()
+
+			if recorder.statusCode == 0 || recorder.statusCode >= http.StatusInternalServerError {
+				if err := store.DeleteIdempotencyKey(ctx, storageKey); err != nil {
+					slog.ErrorContext(ctx, "DeleteIdempotencyKey: function error", "error", err)
+				}
+				return
+			}
+
+			record.StatusCode = recorder.statusCode
+			record.ContentType = recorder.Header().Get("Content-Type")
+			record.Body = recorder.body.Bytes()
+
+			if err := store.SaveIdempotencyResponse(ctx, record); err != nil {
+				slog.ErrorContext(ctx, "SaveIdempotencyResponse: function error", "error", err)
+			}
+		})
+	}
+}
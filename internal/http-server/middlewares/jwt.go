@@ -1,100 +1,382 @@
 package middlewares
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"todo_restapi/internal/apperrors"
 	"todo_restapi/internal/config"
+	"todo_restapi/internal/metrics"
+	"todo_restapi/internal/models"
+	"todo_restapi/internal/services"
+	"todo_restapi/internal/signing"
+	"todo_restapi/internal/storage"
+)
+
+var (
+	errInvalidCredentials  = apperrors.Unauthorized("invalid_credentials", "invalid login or password")
+	errInvalidRefreshToken = apperrors.Unauthorized("invalid_refresh_token", "refresh token is invalid or expired")
+	errRefreshTokenReused  = apperrors.Unauthorized("refresh_token_reused",
+		"refresh token has already been used, the sign-in was revoked")
+	errTokenNotFound = errors.New("token not found")
 )
 
 type AuthService struct {
-	Config *config.Config
+	Config  *config.Shared
+	Storage *storage.Storage
+	Limiter *LoginLimiter
+	Keys    *signing.KeySet
+	Now     func() time.Time
+
+	touches *sessionTouches
 }
 
-func NewAuthService(cfg *config.Config) *AuthService {
-	return &AuthService{Config: cfg}
+func NewAuthService(cfg *config.Shared, storage *storage.Storage) *AuthService {
+	return &AuthService{Config: cfg, Storage: storage, Limiter: NewLoginLimiter(), Now: time.Now,
+		touches: newSessionTouches()}
 }
 
-func (a *AuthService) validatePWD(password string) (bool, error) {
+// LoadSigningKeys reads the configured signing keys. Without them tokens are
+// signed with the shared secret, taken from the current configuration on
+// every use so that a reloaded secret applies at once.
+func (a *AuthService) LoadSigningKeys() error {
+
+	cfg := a.Config.Get()
+	if len(cfg.SigningKeys) == 0 {
+		a.Keys = nil
+		return nil
+	}
 
-	if password != a.Config.Password {
-		return false, errors.New("invalid password")
+	keys, err := signing.LoadKeySet(cfg.SigningKeys)
+	if err != nil {
+		return fmt.Errorf("LoadKeySet: function error: %w", err)
 	}
 
-	return true, nil
+	a.Keys = keys
+	return nil
 }
 
-func (a *AuthService) GenerateJWT(password string) (string, error) {
+func (a *AuthService) keys() *signing.KeySet {
 
-	if ok, err := a.validatePWD(password); !ok {
-		return "", fmt.Errorf("ValidatePWD: function error: %w", err)
+	if a.Keys == nil {
+		return signing.NewHMACKeySet(a.Config.Get().SecretKey)
 	}
 
-	hash := sha256.New()
-	hash.Write([]byte(password))
-	hashPassword := hex.EncodeToString(hash.Sum(nil))
+	return a.Keys
+}
 
-	payload := jwt.MapClaims{
-		"exp": time.Now().Add(time.Hour * 8).Unix(),
-		"pwd": hashPassword,
+// JWKS returns the public verification keys, empty when tokens are signed
+// with the shared secret.
+func (a *AuthService) JWKS() signing.JSONWebKeySet {
+	return a.keys().JWKS()
+}
+
+// authenticate checks the credentials of a registered user. An empty login
+// selects the built-in admin, whose password comes from the configuration
+// either in plaintext or as a bcrypt/argon2id hash.
+func (a *AuthService) authenticate(ctx context.Context, login string, password string) (models.User, error) {
+
+	var user models.User
+	var err error
+
+	if login == "" {
+		user, err = a.Storage.GetUser(ctx, storage.AdminUserID)
+	} else {
+		user, err = a.Storage.GetUserByLogin(ctx, login)
+	}
+	if errors.Is(err, storage.ErrUserNotFound) {
+		services.RejectPassword(password)
+		return user, errInvalidCredentials.Wrap(err)
+	} else if err != nil {
+		return user, fmt.Errorf("GetUser: function error: %w", err)
+	}
+
+	if user.ID == storage.AdminUserID && user.PasswordHash == "" {
+		if !a.checkConfigPassword(password) {
+			return user, errInvalidCredentials
+		}
+		return user, nil
+	}
+
+	if !services.CheckPassword(user.PasswordHash, password) {
+		return user, errInvalidCredentials
+	}
+
+	return user, nil
+}
+
+func (a *AuthService) checkConfigPassword(password string) bool {
+
+	configured := a.Config.Get().Password
+	if services.IsPasswordHash(configured) {
+		return services.CheckPassword(configured, password)
+	}
+
+	return services.CheckPlainPassword(configured, password)
+}
+
+// TokenPair is what a successful sign-in or refresh hands to the client: a
+// short-lived access token and a single-use refresh token for the next pair.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+func (a *AuthService) GenerateJWT(ctx context.Context, client ClientInfo, credentials Credentials) (TokenPair, error) {
+
+	login := credentials.Login
+
+	if err := a.Limiter.Check(client, login); err != nil {
+		return TokenPair{}, err
 	}
 
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
-	signedToken, err := jwtToken.SignedString([]byte(a.Config.SecretKey))
+	user, err := a.authenticate(ctx, login, credentials.Password)
+	if err == nil {
+		err = a.checkSecondFactor(ctx, user, credentials)
+	}
+	if errors.Is(err, errInvalidCredentials) || errors.Is(err, errInvalidOTP) {
+		a.Limiter.Failure(ctx, client, login)
+	}
 	if err != nil {
-		return "", fmt.Errorf("cannot sign JWT: %w", err)
+		return TokenPair{}, fmt.Errorf("authenticate: function error: %w", err)
 	}
+	a.Limiter.Success(login)
 
-	return signedToken, nil
+	return a.StartSession(ctx, a.Storage, user.ID, client)
 }
 
-func (a *AuthService) ValidateJWT(request *http.Request) error {
+// StartSession opens a new refresh token family for the user. It does not
+// check credentials: sign-in does that first, and the token command mints
+// tokens for testing directly.
+func (a *AuthService) StartSession(ctx context.Context, store *storage.Storage, userID int64, client ClientInfo) (TokenPair, error) {
 
-	cookie, err := request.Cookie("token")
+	familyID, err := services.RandomToken(16)
 	if err != nil {
-		return errors.New("token not found")
+		return TokenPair{}, err
 	}
 
-	token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
+	var pair TokenPair
+
+	err = store.WithTx(ctx, func(tx *storage.Storage) error {
+		session := models.Session{ID: familyID, UserID: userID, IP: client.IP, UserAgent: client.UserAgent}
+		if err := tx.CreateTokenFamily(ctx, session); err != nil {
+			return fmt.Errorf("CreateTokenFamily: function error: %w", err)
 		}
-		return []byte(a.Config.SecretKey), nil
+
+		pair, err = a.issueTokens(ctx, tx, userID, familyID)
+		return err
 	})
-	if err != nil {
+
+	return pair, err
+}
+
+// refreshReuseGrace is how long a rotated refresh token still yields a new
+// pair. Every tab of the web interface refreshes with the same cookie, and
+// two of them racing must not look like a stolen token.
+const refreshReuseGrace = 10 * time.Second
+
+// RefreshTokens exchanges a refresh token for a new pair. Every refresh token
+// works once; presenting a used one after refreshReuseGrace means it leaked,
+// so the whole family issued since that sign-in is revoked.
+func (a *AuthService) RefreshTokens(ctx context.Context, refreshToken string) (TokenPair, error) {
+
+	var pair TokenPair
+	var reused bool
+	now := a.Now()
+
+	err := a.Storage.WithTx(ctx, func(tx *storage.Storage) error {
+		token, err := tx.GetRefreshToken(ctx, services.HashToken(refreshToken))
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			return errInvalidRefreshToken.Wrap(err)
+		} else if err != nil {
+			return fmt.Errorf("GetRefreshToken: function error: %w", err)
+		}
+
+		if token.Revoked || now.After(token.ExpiresAt) {
+			return errInvalidRefreshToken
+		}
+
+		if token.Used && now.Sub(token.UsedAt) <= refreshReuseGrace {
+			pair, err = a.issueTokens(ctx, tx, token.UserID, token.FamilyID)
+			return err
+		}
+
+		if token.Used {
+			reused = true
+			if err := tx.RevokeTokenFamily(ctx, token.FamilyID); err != nil {
+				return fmt.Errorf("RevokeTokenFamily: function error: %w", err)
+			}
+			return nil
+		}
+
+		if err := tx.MarkRefreshTokenUsed(ctx, token.TokenHash, now); err != nil {
+			return fmt.Errorf("MarkRefreshTokenUsed: function error: %w", err)
+		}
+
+		if err := tx.TouchSession(ctx, token.FamilyID, now); err != nil {
+			return fmt.Errorf("TouchSession: function error: %w", err)
+		}
+
+		pair, err = a.issueTokens(ctx, tx, token.UserID, token.FamilyID)
 		return err
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	if reused {
+		slog.WarnContext(ctx, "refresh token reuse detected, token family revoked")
+		metrics.AuthFailure("refresh_token_reuse")
+		return TokenPair{}, errRefreshTokenReused
+	}
+
+	return pair, nil
+}
+
+// SignOut revokes the access token in use and the refresh tokens issued
+// together with it.
+func (a *AuthService) SignOut(ctx context.Context, identity Identity) error {
+
+	return a.Storage.WithTx(ctx, func(tx *storage.Storage) error {
+		if err := tx.RevokeToken(ctx, identity.TokenID, identity.ExpiresAt); err != nil {
+			return fmt.Errorf("RevokeToken: function error: %w", err)
+		}
+
+		if err := tx.RevokeTokenFamily(ctx, identity.SessionID); err != nil {
+			return fmt.Errorf("RevokeTokenFamily: function error: %w", err)
+		}
+		return nil
+	})
+}
+
+func (a *AuthService) issueTokens(ctx context.Context, tx *storage.Storage, userID int64, familyID string) (TokenPair, error) {
+
+	now := time.Now()
+	cfg := a.Config.Get()
+
+	tokenID, err := services.RandomToken(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	payload := jwt.MapClaims{
+		"exp": now.Add(cfg.AccessTokenTTL).Unix(),
+		"iat": now.Unix(),
+		"sub": strconv.FormatInt(userID, 10),
+		"jti": tokenID,
+		"sid": familyID,
+	}
+
+	signedToken, err := a.keys().Sign(payload)
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("cannot sign JWT: %w", err)
+	}
+
+	refreshToken, err := services.RandomToken(32)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	err = tx.AddRefreshToken(ctx, services.HashToken(refreshToken), familyID, now.Add(cfg.RefreshTokenTTL))
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("AddRefreshToken: function error: %w", err)
+	}
+
+	return TokenPair{AccessToken: signedToken, RefreshToken: refreshToken, ExpiresIn: cfg.AccessTokenTTL}, nil
+}
+
+// Authenticate accepts an access token or an API key, either as a bearer
+// token in the Authorization header or, for the web interface, in the token
+// cookie.
+func (a *AuthService) Authenticate(request *http.Request) (Identity, error) {
+
+	var token string
+
+	if header := request.Header.Get("Authorization"); header != "" {
+		scheme, credentials, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return Identity{}, errors.New("unsupported authorization scheme")
+		}
+		token = strings.TrimSpace(credentials)
+	} else if cookie, err := request.Cookie("token"); err == nil {
+		token = cookie.Value
+	}
+
+	if token == "" {
+		return Identity{}, errTokenNotFound
+	}
+
+	if strings.HasPrefix(token, APIKeyPrefix) {
+		return a.ValidateAPIKey(request.Context(), token)
+	}
+
+	return a.ValidateJWT(request.Context(), token)
+}
+
+// ValidateJWT returns the identity the access token was issued to, unless the
+// token or its sign-in has been revoked.
+func (a *AuthService) ValidateJWT(ctx context.Context, tokenString string) (Identity, error) {
+
+	keys := a.keys()
+
+	token, err := jwt.Parse(tokenString, keys.Keyfunc, jwt.WithValidMethods(keys.Methods()))
+	if err != nil {
+		return Identity{}, err
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return errors.New("invalid token")
+		return Identity{}, errors.New("invalid token")
 	}
 
+	var identity Identity
+
 	if exp, ok := claims["exp"].(float64); ok {
-		if time.Now().Unix() > int64(exp) {
-			return errors.New("token expired")
+		identity.ExpiresAt = time.Unix(int64(exp), 0)
+		if time.Now().After(identity.ExpiresAt) {
+			return Identity{}, errors.New("token expired")
 		}
 	} else {
-		return errors.New("missing exp claim")
+		return Identity{}, errors.New("missing exp claim")
 	}
 
-	hashFromToken, ok := claims["pwd"].(string)
+	subject, ok := claims["sub"].(string)
 	if !ok {
-		return errors.New("missing password hash in token")
+		return Identity{}, errors.New("missing sub claim")
 	}
 
-	hash := sha256.New()
-	hash.Write([]byte(a.Config.Password))
-	hashPassword := hex.EncodeToString(hash.Sum(nil))
+	identity.UserID, err = strconv.ParseInt(subject, 10, 64)
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid sub claim: %w", err)
+	}
 
-	if hashFromToken != hashPassword {
-		return errors.New("invalid token: password hash mismatch")
+	identity.TokenID, _ = claims["jti"].(string)
+	identity.SessionID, _ = claims["sid"].(string)
+	if identity.TokenID == "" || identity.SessionID == "" {
+		return Identity{}, errors.New("missing jti or sid claim")
 	}
 
-	return nil
+	revoked, err := a.Storage.IsTokenRevoked(ctx, identity.TokenID, identity.SessionID)
+	if err != nil {
+		return Identity{}, apperrors.Internal(fmt.Errorf("IsTokenRevoked: function error: %w", err))
+	}
+	if revoked {
+		return Identity{}, errors.New("token revoked")
+	}
+
+	if now := a.Now(); a.touches.due(identity.SessionID, now) {
+		if err := a.Storage.TouchSession(ctx, identity.SessionID, now); err != nil {
+			slog.ErrorContext(ctx, "TouchSession: function error", "error", err)
+		}
+	}
+
+	return identity, nil
 }
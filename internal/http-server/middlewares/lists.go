@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/models"
+	"todo_restapi/internal/services"
+	"todo_restapi/internal/storage"
+)
+
+const listAccessKey contextKey = "list_access"
+
+// ListAccess is the shared list a request works on and the caller's role in
+// it, set by RequireListRole.
+type ListAccess struct {
+	ListID int64
+	Role   models.Role
+}
+
+func ListAccessFrom(ctx context.Context) (ListAccess, bool) {
+	access, ok := ctx.Value(listAccessKey).(ListAccess)
+	return access, ok
+}
+
+// TaskScope returns the tasks a request works on: the shared list resolved
+// by RequireListRole, or the caller's personal tasks.
+func TaskScope(ctx context.Context) storage.TaskScope {
+
+	scope := storage.TaskScope{OwnerID: UserID(ctx)}
+	if access, ok := ListAccessFrom(ctx); ok {
+		scope.ListID = access.ListID
+	}
+
+	return scope
+}
+
+// RequireListRole loads the caller's membership in the {listID} list and
+// checks readRole for safe methods and writeRole for everything else.
+// Non-members get 404, so list ids cannot be probed.
+func RequireListRole(store *storage.Storage, readRole models.Role, writeRole models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+
+			listID, err := strconv.ParseInt(chi.URLParam(request, "listID"), 10, 64)
+			if err != nil {
+				services.WriteProblem(write, request, apperrors.Validation("validation_failed", "invalid list id",
+					apperrors.Field("listID", "invalid", "list id must be an integer")).Wrap(err))
+				return
+			}
+
+			role, err := store.ListRole(request.Context(), listID, UserID(request.Context()))
+			if errors.Is(err, storage.ErrListNotFound) {
+				services.WriteProblem(write, request, apperrors.NotFound("list_not_found", "list not found").Wrap(err))
+				return
+			} else if err != nil {
+				services.WriteProblem(write, request, fmt.Errorf("ListRole: function error: %w", err))
+				return
+			}
+
+			required := writeRole
+			if request.Method == http.MethodGet || request.Method == http.MethodHead {
+				required = readRole
+			}
+
+			if !role.Allows(required) {
+				services.WriteProblem(write, request,
+					apperrors.Forbidden("insufficient_role", fmt.Sprintf("this action requires the %s role", required)))
+				return
+			}
+
+			ctx := context.WithValue(request.Context(), listAccessKey, ListAccess{ListID: listID, Role: role})
+			next.ServeHTTP(write, request.WithContext(ctx))
+		})
+	}
+}
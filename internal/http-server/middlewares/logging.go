@@ -0,0 +1,137 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todo_restapi/internal/logger"
+	"todo_restapi/internal/metrics"
+	"todo_restapi/internal/services"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+	requestIDSize      = 12
+)
+
+// RequestID reuses the caller's X-Request-ID when it looks sane, so that a
+// request can be followed through a proxy, and generates one otherwise.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+
+		id := request.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			var err error
+			if id, err = services.RandomToken(requestIDSize); err != nil {
+				id = "-"
+			}
+		}
+
+		write.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(write, request.WithContext(logger.WithRequestID(request.Context(), id)))
+	})
+}
+
+func validRequestID(id string) bool {
+
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode int
+	size       int
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// AccessLog writes one line per request. The route is the chi pattern, so
+// lines for /api/v1/tasks/1 and /api/v1/tasks/2 can be grouped.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+
+		start := time.Now()
+		recorder := &statusWriter{ResponseWriter: write}
+
+		next.ServeHTTP(recorder, request)
+
+		if recorder.statusCode == 0 {
+			recorder.statusCode = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if recorder.statusCode >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		slog.LogAttrs(request.Context(), level, "request",
+			slog.String("method", request.Method),
+			slog.String("route", routePattern(request)),
+			slog.String("path", request.URL.Path),
+			slog.Int("status", recorder.statusCode),
+			slog.Int("bytes", recorder.size),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("ip", NewClientInfo(request).IP),
+		)
+	})
+}
+
+// Metrics records request counts and latencies per chi route pattern.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+
+		start := time.Now()
+		recorder := &statusWriter{ResponseWriter: write}
+
+		next.ServeHTTP(recorder, request)
+
+		if recorder.statusCode == 0 {
+			recorder.statusCode = http.StatusOK
+		}
+
+		metrics.ObserveRequest(request.Method, routePattern(request), recorder.statusCode, time.Since(start))
+	})
+}
+
+func routePattern(request *http.Request) string {
+
+	if routeContext := chi.RouteContext(request.Context()); routeContext != nil {
+		return routeContext.RoutePattern()
+	}
+
+	return ""
+}
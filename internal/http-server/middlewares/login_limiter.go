@@ -0,0 +1,216 @@
+package middlewares
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/metrics"
+)
+
+const (
+	maxTrackedLogins   = 10000
+	maxUserAgentLength = 512
+)
+
+// AuthStats is published at /debug/vars.
+var AuthStats = expvar.NewMap("auth")
+
+type loginAttempts struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// LoginLimiter counts failed sign-ins per client IP and per account. Once a
+// key runs out of free attempts every further failure locks it for twice as
+// long as the previous one, up to MaxLockout. Counters are forgotten Window
+// after the last failure. At most MaxTracked keys are kept, so failures with
+// random logins cannot grow the counters without bound.
+type LoginLimiter struct {
+	AccountAttempts int
+	IPAttempts      int
+	BaseLockout     time.Duration
+	MaxLockout      time.Duration
+	Window          time.Duration
+	MaxTracked      int
+	Now             func() time.Time
+
+	mu       sync.Mutex
+	attempts map[string]*loginAttempts
+}
+
+func NewLoginLimiter() *LoginLimiter {
+	return &LoginLimiter{
+		AccountAttempts: 5,
+		IPAttempts:      20,
+		BaseLockout:     30 * time.Second,
+		MaxLockout:      15 * time.Minute,
+		Window:          time.Hour,
+		MaxTracked:      maxTrackedLogins,
+		Now:             time.Now,
+		attempts:        map[string]*loginAttempts{},
+	}
+}
+
+// ClientInfo describes where a sign-in comes from.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+func NewClientInfo(request *http.Request) ClientInfo {
+
+	ip, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		ip = request.RemoteAddr
+	}
+
+	userAgent := request.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	return ClientInfo{IP: ip, UserAgent: userAgent}
+}
+
+func accountKey(login string) string {
+
+	if login == "" {
+		login = "admin"
+	}
+	return "account:" + strings.ToLower(login)
+}
+
+// Check returns an error while either the IP or the account is locked out.
+func (l *LoginLimiter) Check(client ClientInfo, login string) error {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Now()
+	var retryAfter time.Duration
+
+	for _, key := range []string{"ip:" + client.IP, accountKey(login)} {
+		if entry, ok := l.attempts[key]; ok && entry.lockedUntil.After(now) {
+			retryAfter = max(retryAfter, entry.lockedUntil.Sub(now))
+		}
+	}
+
+	if retryAfter > 0 {
+		AuthStats.Add("throttled_signins", 1)
+		metrics.AuthFailure("throttled")
+		return apperrors.TooManyRequests("too_many_attempts", "too many failed sign-in attempts, try again later",
+			retryAfter)
+	}
+
+	return nil
+}
+
+func (l *LoginLimiter) Failure(ctx context.Context, client ClientInfo, login string) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Now()
+	ipFailures := l.fail(ctx, "ip:"+client.IP, l.IPAttempts, now)
+	accountFailures := l.fail(ctx, accountKey(login), l.AccountAttempts, now)
+
+	AuthStats.Add("failed_signins", 1)
+	metrics.AuthFailure("invalid_credentials")
+	slog.WarnContext(ctx, "failed sign-in", "login", login, "ip", client.IP,
+		"account_failures", accountFailures, "ip_failures", ipFailures)
+}
+
+// Success clears the account counter. The IP counter is kept, so one valid
+// account does not reset the budget for guessing the others.
+func (l *LoginLimiter) Success(login string) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, accountKey(login))
+}
+
+func (l *LoginLimiter) fail(ctx context.Context, key string, freeAttempts int, now time.Time) int {
+
+	entry, ok := l.attempts[key]
+	if !ok {
+		l.makeRoom(ctx, now)
+		entry = &loginAttempts{}
+		l.attempts[key] = entry
+	} else if now.Sub(entry.lastFailure) > l.Window {
+		*entry = loginAttempts{}
+	}
+
+	entry.failures++
+	entry.lastFailure = now
+
+	if extra := entry.failures - freeAttempts; extra >= 0 {
+		lockout := l.BaseLockout
+		for i := 0; i < extra && lockout < l.MaxLockout; i++ {
+			lockout *= 2
+		}
+		lockout = min(lockout, l.MaxLockout)
+		entry.lockedUntil = now.Add(lockout)
+		AuthStats.Add("lockouts", 1)
+		slog.WarnContext(ctx, "sign-in locked", "key", key, "lockout", lockout, "failures", entry.failures)
+	}
+
+	return entry.failures
+}
+
+// Tracked returns the number of keys the limiter currently holds.
+func (l *LoginLimiter) Tracked() int {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.attempts)
+}
+
+// makeRoom frees a slot for a new key when MaxTracked keys are held: first
+// the expired ones, then the unlocked key with the oldest failure and, when
+// every key is locked, the lock that ends first. A new failure is always
+// tracked, so filling the table with locked keys does not switch the
+// limiter off for everyone else.
+func (l *LoginLimiter) makeRoom(ctx context.Context, now time.Time) {
+
+	if len(l.attempts) < l.MaxTracked {
+		return
+	}
+
+	var oldestKey, soonestKey string
+	var oldest, soonest *loginAttempts
+
+	for key, entry := range l.attempts {
+		if entry.lockedUntil.After(now) {
+			if soonest == nil || entry.lockedUntil.Before(soonest.lockedUntil) {
+				soonestKey, soonest = key, entry
+			}
+			continue
+		}
+		if now.Sub(entry.lastFailure) > l.Window {
+			delete(l.attempts, key)
+			continue
+		}
+		if oldest == nil || entry.lastFailure.Before(oldest.lastFailure) {
+			oldestKey, oldest = key, entry
+		}
+	}
+
+	switch {
+	case len(l.attempts) < l.MaxTracked:
+	case oldest != nil:
+		delete(l.attempts, oldestKey)
+	default:
+		slog.WarnContext(ctx, "sign-in limiter is full of locked keys, lifting the one that ends first", "key", soonestKey)
+		delete(l.attempts, soonestKey)
+	}
+}
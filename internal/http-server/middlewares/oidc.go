@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/oidc"
+	"todo_restapi/internal/services"
+	"todo_restapi/internal/storage"
+)
+
+var invalidLoginChars = regexp.MustCompile(`[^A-Za-z0-9_.@-]`)
+
+// SignInOIDC starts a session for the local user linked to the provider
+// account, creating and linking one on first sign-in if registration is open.
+func (a *AuthService) SignInOIDC(ctx context.Context, client ClientInfo, claims oidc.Claims) (TokenPair, error) {
+
+	var pair TokenPair
+
+	err := a.Storage.WithTx(ctx, func(tx *storage.Storage) error {
+		userID, err := tx.GetUserIDByIdentity(ctx, claims.Issuer, claims.Subject)
+		if errors.Is(err, storage.ErrUserNotFound) {
+			if !a.Config.Get().AllowRegistration {
+				return apperrors.Forbidden("registration_disabled", "registration is disabled")
+			}
+			userID, err = createOIDCUser(ctx, tx, claims)
+			if err != nil {
+				return err
+			}
+		} else if err != nil {
+			return fmt.Errorf("GetUserIDByIdentity: function error: %w", err)
+		}
+
+		pair, err = a.StartSession(ctx, tx, userID, client)
+		return err
+	})
+
+	return pair, err
+}
+
+// createOIDCUser picks a free login based on the provider's username or
+// e-mail. The account has no password and can only sign in through OIDC.
+func createOIDCUser(ctx context.Context, tx *storage.Storage, claims oidc.Claims) (int64, error) {
+
+	base := claims.PreferredUsername
+	if base == "" {
+		base = claims.Email
+	}
+	base = invalidLoginChars.ReplaceAllString(base, "")
+	if len(base) > 56 {
+		base = base[:56]
+	}
+	if len(base) < 3 {
+		base = "oidc_" + services.HashToken(claims.Issuer + " " + claims.Subject)[:8]
+	}
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		login := base
+		if attempt > 1 {
+			login = fmt.Sprintf("%s-%d", base, attempt)
+		}
+
+		userID, err := tx.CreateUser(ctx, login, "")
+		if errors.Is(err, storage.ErrUserExists) {
+			continue
+		} else if err != nil {
+			return 0, fmt.Errorf("CreateUser: function error: %w", err)
+		}
+
+		if err := tx.LinkIdentity(ctx, claims.Issuer, claims.Subject, userID); err != nil {
+			return 0, fmt.Errorf("LinkIdentity: function error: %w", err)
+		}
+		return userID, nil
+	}
+
+	return 0, fmt.Errorf("no free login for %q", strings.ToLower(base))
+}
@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	sessionTouchInterval = time.Minute
+	maxTrackedSessions   = 10000
+)
+
+// sessionTouches remembers when each session was last written to the
+// database, so that authenticated requests take the SQLite write lock for
+// TouchSession at most once a minute per session instead of on every read.
+type sessionTouches struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func newSessionTouches() *sessionTouches {
+	return &sessionTouches{last: map[string]time.Time{}}
+}
+
+// due reports whether the session should be touched at now and, if so,
+// records that it was. When more than maxTrackedSessions sessions were seen
+// within the interval the record starts over, which costs one extra write
+// per session rather than unbounded memory.
+func (s *sessionTouches) due(sessionID string, now time.Time) bool {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.last[sessionID]; ok && now.Sub(last) < sessionTouchInterval {
+		return false
+	}
+
+	if len(s.last) >= maxTrackedSessions {
+		for id, last := range s.last {
+			if now.Sub(last) >= sessionTouchInterval {
+				delete(s.last, id)
+			}
+		}
+		if len(s.last) >= maxTrackedSessions {
+			clear(s.last)
+		}
+	}
+
+	s.last[sessionID] = now
+	return true
+}
@@ -0,0 +1,213 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/models"
+	"todo_restapi/internal/services"
+	"todo_restapi/internal/storage"
+)
+
+const (
+	totpIssuer         = "Todo Scheduler"
+	recoveryCodesCount = 10
+)
+
+var (
+	errOTPRequired        = apperrors.Unauthorized("otp_required", "one-time code from the authenticator app is required")
+	errInvalidOTP         = apperrors.Unauthorized("invalid_otp", "invalid one-time code")
+	errTOTPAlreadyEnabled = apperrors.Conflict("totp_enabled", "two-factor authentication is already enabled")
+	errTOTPNotEnrolled    = apperrors.Conflict("totp_not_enrolled", "two-factor authentication enrollment was not started")
+	errTOTPNotEnabled     = apperrors.Conflict("totp_not_enabled", "two-factor authentication is not enabled")
+
+	// A signed-in user who mistypes a code gets 400, not 401: clients treat
+	// 401 as the end of the session.
+	errCodeRequired = apperrors.Validation("otp_required", "one-time code from the authenticator app is required")
+	errWrongCode    = apperrors.Validation("invalid_otp", "invalid one-time code")
+)
+
+// Credentials are what a client presents at sign-in. OTP or RecoveryCode is
+// only needed once the user has enabled two-factor authentication.
+type Credentials struct {
+	Login        string
+	Password     string
+	OTP          string
+	RecoveryCode string
+}
+
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
+
+func (a *AuthService) checkSecondFactor(ctx context.Context, user models.User, credentials Credentials) error {
+
+	if !user.TOTPEnabled {
+		return nil
+	}
+
+	if credentials.RecoveryCode != "" {
+		codeHash := services.HashToken(services.NormalizeRecoveryCode(credentials.RecoveryCode))
+		used, err := a.Storage.UseRecoveryCode(ctx, user.ID, codeHash)
+		if err != nil {
+			return fmt.Errorf("UseRecoveryCode: function error: %w", err)
+		}
+		if !used {
+			return errInvalidOTP
+		}
+		return nil
+	}
+
+	if credentials.OTP == "" {
+		return errOTPRequired
+	}
+
+	return a.useTOTPCode(ctx, a.Storage, user, credentials.OTP)
+}
+
+func (a *AuthService) useTOTPCode(ctx context.Context, store *storage.Storage, user models.User, code string) error {
+
+	step, ok := services.ValidateTOTP(user.TOTPSecret, code, a.Now())
+	if !ok {
+		return errInvalidOTP
+	}
+
+	fresh, err := store.UseTOTPStep(ctx, user.ID, step)
+	if err != nil {
+		return fmt.Errorf("UseTOTPStep: function error: %w", err)
+	}
+	if !fresh {
+		return errInvalidOTP.Wrap(errors.New("code was already used"))
+	}
+
+	return nil
+}
+
+func (a *AuthService) EnrollTOTP(ctx context.Context, userID int64) (TOTPEnrollment, error) {
+
+	user, err := a.Storage.GetUser(ctx, userID)
+	if err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("GetUser: function error: %w", err)
+	}
+	if user.TOTPEnabled {
+		return TOTPEnrollment{}, errTOTPAlreadyEnabled
+	}
+
+	secret, err := services.GenerateTOTPSecret()
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	if err := a.Storage.SetTOTPSecret(ctx, userID, secret); err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("SetTOTPSecret: function error: %w", err)
+	}
+
+	return TOTPEnrollment{
+		Secret: secret,
+		URI:    services.TOTPProvisioningURI(totpIssuer, user.Login, secret),
+	}, nil
+}
+
+// ConfirmTOTP turns two-factor authentication on once the user proves the
+// authenticator app works, and returns the recovery codes to show once.
+// Wrong codes count against the sign-in limiter of the account.
+func (a *AuthService) ConfirmTOTP(ctx context.Context, client ClientInfo, userID int64, code string) ([]string, error) {
+
+	var codes []string
+
+	err := a.Storage.WithTx(ctx, func(tx *storage.Storage) error {
+		user, err := tx.GetUser(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("GetUser: function error: %w", err)
+		}
+		if user.TOTPEnabled {
+			return errTOTPAlreadyEnabled
+		}
+		if user.TOTPSecret == "" {
+			return errTOTPNotEnrolled
+		}
+
+		if err := a.Limiter.Check(client, user.Login); err != nil {
+			return err
+		}
+
+		if err := a.useTOTPCode(ctx, tx, user, code); err != nil {
+			if errors.Is(err, errInvalidOTP) {
+				a.Limiter.Failure(ctx, client, user.Login)
+				return errWrongCode.Wrap(err)
+			}
+			return err
+		}
+
+		codes, err = services.GenerateRecoveryCodes(recoveryCodesCount)
+		if err != nil {
+			return err
+		}
+
+		hashes := make([]string, 0, len(codes))
+		for _, code := range codes {
+			hashes = append(hashes, services.HashToken(code))
+		}
+
+		if err := tx.EnableTOTP(ctx, userID, hashes); err != nil {
+			return fmt.Errorf("EnableTOTP: function error: %w", err)
+		}
+		return nil
+	})
+
+	return codes, err
+}
+
+// DisableTOTP turns two-factor authentication off after a valid code. Like
+// at sign-in, wrong codes count against the limiter of the account, so a
+// stolen access token is not enough to guess one.
+func (a *AuthService) DisableTOTP(ctx context.Context, client ClientInfo, userID int64, credentials Credentials) error {
+
+	user, err := a.Storage.GetUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("GetUser: function error: %w", err)
+	}
+	if !user.TOTPEnabled {
+		return errTOTPNotEnabled
+	}
+
+	if credentials.OTP == "" && credentials.RecoveryCode == "" {
+		return errCodeRequired
+	}
+
+	if err := a.Limiter.Check(client, user.Login); err != nil {
+		return err
+	}
+
+	if err := a.checkSecondFactor(ctx, user, credentials); err != nil {
+		if errors.Is(err, errInvalidOTP) {
+			a.Limiter.Failure(ctx, client, user.Login)
+			return errWrongCode.Wrap(err)
+		}
+		return err
+	}
+
+	if err := a.Storage.DisableTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("DisableTOTP: function error: %w", err)
+	}
+
+	return nil
+}
+
+func (a *AuthService) TOTPStatus(ctx context.Context, userID int64) (bool, int, error) {
+
+	user, err := a.Storage.GetUser(ctx, userID)
+	if err != nil {
+		return false, 0, fmt.Errorf("GetUser: function error: %w", err)
+	}
+
+	count, err := a.Storage.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return false, 0, fmt.Errorf("CountRecoveryCodes: function error: %w", err)
+	}
+
+	return user.TOTPEnabled, count, nil
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "todo_restapi/internal/http-server"
+
+// Tracing starts a server span for every request, continuing the trace from
+// the traceparent header when there is one. The span is named after the chi
+// route once routing is done.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+
+		ctx := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+		ctx, span := otel.Tracer(tracerName).Start(ctx, request.Method, trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", request.Method),
+				attribute.String("url.path", request.URL.Path),
+				attribute.String("client.address", NewClientInfo(request).IP),
+			))
+		defer span.End()
+
+		recorder := &statusWriter{ResponseWriter: write}
+		next.ServeHTTP(recorder, request.WithContext(ctx))
+
+		if recorder.statusCode == 0 {
+			recorder.statusCode = http.StatusOK
+		}
+
+		route := routePattern(request)
+		span.SetName(request.Method + " " + route)
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", recorder.statusCode),
+		)
+
+		if recorder.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.statusCode))
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/services"
+)
+
+//go:embed openapi.json
+var Spec []byte
+
+func Load() (*openapi3.T, error) {
+
+	loader := openapi3.NewLoader()
+
+	doc, err := loader.LoadFromData(Spec)
+	if err != nil {
+		return nil, fmt.Errorf("spec load error: %w", err)
+	}
+
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("spec validation error: %w", err)
+	}
+
+	return doc, nil
+}
+
+func Handler(write http.ResponseWriter, request *http.Request) {
+
+	write.Header().Set("Content-Type", "application/json")
+	write.WriteHeader(http.StatusOK)
+
+	if _, err := write.Write(Spec); err != nil {
+		http.Error(write, "failed to write response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Validator rejects requests whose parameters or body do not match doc.
+// Requests for paths the document does not describe are passed through.
+func Validator(doc *openapi3.T) (func(http.Handler) http.Handler, error) {
+
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("router create error: %w", err)
+	}
+
+	options := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+
+			route, pathParams, err := router.FindRoute(request)
+			if err != nil {
+				next.ServeHTTP(write, request)
+				return
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    request,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    options,
+			}
+
+			if err := openapi3filter.ValidateRequest(request.Context(), input); err != nil {
+				services.WriteProblem(write, request, validationError(err))
+				return
+			}
+
+			next.ServeHTTP(write, request)
+		})
+	}, nil
+}
+
+func validationError(err error) error {
+
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return apperrors.Validation("validation_failed", "request does not match the API specification").Wrap(err)
+	}
+
+	field := "body"
+	code := "invalid"
+	message := requestErr.Reason
+
+	if requestErr.Parameter != nil {
+		field = requestErr.Parameter.Name
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		if pointer := schemaErr.JSONPointer(); requestErr.Parameter == nil && len(pointer) > 0 {
+			field = strings.Join(pointer, ".")
+		}
+		message = schemaErr.Reason
+	}
+
+	if errors.Is(err, openapi3filter.ErrInvalidRequired) {
+		code = "required"
+	}
+
+	if message == "" && requestErr.Err != nil {
+		message = requestErr.Err.Error()
+	}
+
+	return apperrors.Validation("validation_failed", fmt.Sprintf("invalid %s: %s", field, message),
+		apperrors.Field(field, code, message)).Wrap(err)
+}
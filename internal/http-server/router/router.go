@@ -0,0 +1,170 @@
+package router
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/config"
+	"todo_restapi/internal/constants"
+	"todo_restapi/internal/http-server/handlers"
+	v1 "todo_restapi/internal/http-server/handlers/v1"
+	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/http-server/openapi"
+	"todo_restapi/internal/metrics"
+	"todo_restapi/internal/models"
+	"todo_restapi/internal/services"
+	"todo_restapi/internal/storage"
+)
+
+// New builds the router. Draining is the server's shutdown flag, which turns
+// /readyz into a failure while connections are drained.
+func New(cfg *config.Shared, database *storage.Storage, draining func() bool) (*chi.Mux, error) {
+	return newRouter(cfg, database, middlewares.NewAuthService(cfg, database), draining)
+}
+
+// NewWithAuth lets tests supply an AuthService with a fixed clock.
+func NewWithAuth(cfg *config.Shared, database *storage.Storage, autService *middlewares.AuthService) (*chi.Mux, error) {
+	return newRouter(cfg, database, autService, nil)
+}
+
+func newRouter(cfg *config.Shared, database *storage.Storage, autService *middlewares.AuthService,
+	draining func() bool) (*chi.Mux, error) {
+
+	doc, err := openapi.Load()
+	if err != nil {
+		return nil, fmt.Errorf("openapi.Load: function error: %w", err)
+	}
+
+	validator, err := openapi.Validator(doc)
+	if err != nil {
+		return nil, fmt.Errorf("openapi.Validator: function error: %w", err)
+	}
+
+	if autService.Keys == nil {
+		if err := autService.LoadSigningKeys(); err != nil {
+			return nil, fmt.Errorf("LoadSigningKeys: function error: %w", err)
+		}
+	}
+
+	taskHandler := handlers.NewTaskHandler(database, cfg, autService)
+	v1Handler := v1.NewHandler(database, cfg, autService)
+	oidcHandler := handlers.NewOIDCHandler(cfg, autService)
+	healthHandler := handlers.NewHealthHandler(database, draining)
+	auth := middlewares.Auth(autService)
+	taskScope := middlewares.RequireScope(middlewares.ScopeTasksRead, middlewares.ScopeTasksWrite)
+	idempotency := middlewares.Idempotency(database, cfg.Get().IdempotencyTTL)
+
+	// Every member may read a list; editors change its tasks and owners
+	// manage the list itself and its members.
+	listMember := middlewares.RequireListRole(database, models.RoleViewer, models.RoleViewer)
+	listEditor := middlewares.RequireListRole(database, models.RoleViewer, models.RoleEditor)
+	listOwner := middlewares.RequireListRole(database, models.RoleViewer, models.RoleOwner)
+
+	router := chi.NewRouter()
+	router.Use(middlewares.Tracing, middlewares.RequestID, middlewares.AccessLog, middlewares.Metrics)
+
+	router.MethodNotAllowed(func(write http.ResponseWriter, request *http.Request) {
+		services.WriteProblem(write, request, apperrors.MethodNotAllowed())
+	})
+
+	router.Get("/", func(write http.ResponseWriter, request *http.Request) {
+		http.ServeFile(write, request, "web/index.html")
+	})
+	router.Handle("/*", http.StripPrefix("/", http.FileServer(http.Dir("web"))))
+
+	router.Get("/healthz", healthHandler.Liveness)
+	router.Get("/readyz", healthHandler.Readiness)
+	router.Get("/api/openapi.json", openapi.Handler)
+	router.Get("/.well-known/jwks.json", taskHandler.JWKS)
+	router.With(auth, middlewares.RequireSession, middlewares.RequireAdmin).Get("/debug/vars", expvar.Handler().ServeHTTP)
+	router.Method(http.MethodGet, "/metrics", metrics.Handler(func() (map[string]int, error) {
+		return database.CountTasksByState(context.Background(), time.Now().Format(constants.DateFormat))
+	}, cfg.Get().MetricsToken))
+	router.With(validator).Post("/api/token/refresh", taskHandler.RefreshToken)
+	router.With(validator).Get("/api/oidc/login", oidcHandler.Login)
+	router.With(validator).Get("/api/oidc/callback", oidcHandler.Callback)
+	router.With(auth, middlewares.RequireSession, validator).Post("/api/signout", taskHandler.SignOut)
+
+	router.Group(func(router chi.Router) {
+
+		router.Use(auth, middlewares.RequireSession, validator)
+
+		router.Get("/api/keys", taskHandler.GetAPIKeys)
+		router.Post("/api/keys", taskHandler.CreateAPIKey)
+		router.Delete("/api/keys/{id}", taskHandler.RevokeAPIKey)
+
+		router.Get("/api/sessions", taskHandler.GetSessions)
+		router.Delete("/api/sessions/{id}", taskHandler.RevokeSession)
+
+		router.Get("/api/2fa", taskHandler.GetTOTPStatus)
+		router.Post("/api/2fa/enroll", taskHandler.EnrollTOTP)
+		router.Post("/api/2fa/verify", taskHandler.VerifyTOTP)
+		router.Post("/api/2fa/disable", taskHandler.DisableTOTP)
+	})
+
+	router.Route("/api/v1", func(router chi.Router) {
+
+		router.With(validator).Get("/nextdate", v1Handler.NextDate)
+		router.With(validator).Post("/signin", v1Handler.SignIn)
+		router.With(validator).Post("/signup", v1Handler.SignUp)
+
+		router.Group(func(router chi.Router) {
+
+			router.Use(auth, taskScope, validator)
+
+			router.Get("/tasks", v1Handler.ListTasks)
+			router.With(idempotency).Post("/tasks", v1Handler.CreateTask)
+			router.Get("/tasks/{id}", v1Handler.GetTask)
+			router.Put("/tasks/{id}", v1Handler.UpdateTask)
+			router.Delete("/tasks/{id}", v1Handler.DeleteTask)
+			router.With(idempotency).Post("/tasks/{id}/done", v1Handler.CompleteTask)
+
+			router.Get("/lists", v1Handler.ListLists)
+			router.With(listOwner).Get("/lists/{listID}", v1Handler.GetList)
+			router.With(listOwner).Get("/lists/{listID}/members", v1Handler.ListMembers)
+
+			// Like API key management, sharing and deleting lists takes a
+			// session: a task key must not hand the owner's lists to others.
+			router.With(middlewares.RequireSession).Post("/lists", v1Handler.CreateList)
+			router.With(middlewares.RequireSession, listOwner).Delete("/lists/{listID}", v1Handler.DeleteList)
+			router.With(middlewares.RequireSession, listOwner).Post("/lists/{listID}/members", v1Handler.AddMember)
+			router.With(middlewares.RequireSession, listOwner).Put("/lists/{listID}/members/{userID}", v1Handler.UpdateMember)
+			router.With(middlewares.RequireSession, listMember).Delete("/lists/{listID}/members/{userID}", v1Handler.RemoveMember)
+
+			router.With(listEditor).Get("/lists/{listID}/tasks", v1Handler.ListTasks)
+			router.With(listEditor, idempotency).Post("/lists/{listID}/tasks", v1Handler.CreateTask)
+			router.With(listEditor).Get("/lists/{listID}/tasks/{id}", v1Handler.GetTask)
+			router.With(listEditor).Put("/lists/{listID}/tasks/{id}", v1Handler.UpdateTask)
+			router.With(listEditor).Delete("/lists/{listID}/tasks/{id}", v1Handler.DeleteTask)
+			router.With(listEditor, idempotency).Post("/lists/{listID}/tasks/{id}/done", v1Handler.CompleteTask)
+		})
+	})
+
+	router.Group(func(router chi.Router) {
+
+		router.Use(middlewares.Deprecated("/api/v1"))
+
+		router.With(validator).Get("/api/nextdate", taskHandler.NextDate)
+		router.With(validator).Post("/api/signin", taskHandler.Authentication)
+
+		router.With(auth).Route("/api", func(router chi.Router) {
+
+			router.Use(taskScope, validator)
+
+			router.Get("/task", taskHandler.GetTask)
+			router.With(idempotency).Post("/task", taskHandler.AddTask)
+			router.Put("/task", taskHandler.EditTask)
+			router.Delete("/task", taskHandler.DeleteTask)
+
+			router.Get("/tasks", taskHandler.GetTasks)
+			router.With(idempotency).Post("/task/done", taskHandler.TaskIsDone)
+		})
+	})
+
+	return router, nil
+}
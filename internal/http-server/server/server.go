@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"todo_restapi/internal/config"
+)
+
+// Server is an http.Server that shuts down gracefully when its context is
+// cancelled.
+type Server struct {
+	HTTP            *http.Server
+	ShutdownDelay   time.Duration
+	ShutdownTimeout time.Duration
+
+	draining atomic.Bool
+}
+
+func New(cfg *config.Config, handler http.Handler) *Server {
+	return &Server{
+		HTTP: &http.Server{
+			Addr:              cfg.Port,
+			Handler:           handler,
+			ReadHeaderTimeout: cfg.ReadTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		},
+		ShutdownDelay:   cfg.ShutdownDelay,
+		ShutdownTimeout: cfg.ShutdownTimeout,
+	}
+}
+
+// Draining reports whether shutdown has begun.
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// Serve accepts connections on listener until ctx is cancelled. It then keeps
+// serving for ShutdownDelay, stops accepting new connections and waits up to
+// ShutdownTimeout for in-flight requests before closing the rest.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.HTTP.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("serve error: %w", err)
+	case <-ctx.Done():
+	}
+
+	s.draining.Store(true)
+	slog.Info("shutting down, draining connections", "timeout", s.ShutdownDelay+s.ShutdownTimeout)
+
+	time.Sleep(s.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+
+	if err := s.HTTP.Shutdown(shutdownCtx); err != nil {
+		s.HTTP.Close()
+		return fmt.Errorf("shutdown error: %w", err)
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve error: %w", err)
+	}
+
+	return nil
+}
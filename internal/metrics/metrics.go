@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/services"
+)
+
+const namespace = "todo"
+
+// Registry holds the process-wide collectors. Collectors that need a
+// database are added per handler in Handler.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, chi route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and chi route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	storageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_query_duration_seconds",
+		Help:      "Duration of storage methods such as AddTask or SearchTasks.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"method"})
+
+	authFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Failed sign-ins and rejected credentials by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		httpRequests,
+		httpDuration,
+		storageDuration,
+		authFailures,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+func ObserveRequest(method string, route string, status int, duration time.Duration) {
+
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveQuery records how long the storage method took since start. It is
+// called by Storage.observe when the method returns, next to ending its span.
+func ObserveQuery(method string, start time.Time) {
+	storageDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// AuthFailure counts a rejected sign-in or credential. Reasons are a fixed
+// set: invalid_credentials, throttled, missing_token, invalid_token and
+// refresh_token_reuse.
+func AuthFailure(reason string) {
+	authFailures.WithLabelValues(reason).Inc()
+}
+
+// TaskCounter returns the number of tasks per state and is called on every
+// scrape.
+type TaskCounter func() (map[string]int, error)
+
+type taskCollector struct {
+	count TaskCounter
+	desc  *prometheus.Desc
+}
+
+func (c taskCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- c.desc
+}
+
+func (c taskCollector) Collect(metrics chan<- prometheus.Metric) {
+
+	counts, err := c.count()
+	if err != nil {
+		metrics <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	for state, count := range counts {
+		metrics <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), state)
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format. When token is not
+// empty scrapers have to send it as a bearer token.
+func Handler(tasks TaskCounter, token string) http.Handler {
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(taskCollector{
+		count: tasks,
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "tasks"),
+			"Stored tasks by state: overdue, today or upcoming.", []string{"state"}, nil),
+	})
+
+	handler := promhttp.HandlerFor(prometheus.Gatherers{Registry, registry}, promhttp.HandlerOpts{})
+	if token == "" {
+		return handler
+	}
+
+	expected := []byte("Bearer " + token)
+
+	return http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+
+		if subtle.ConstantTimeCompare([]byte(request.Header.Get("Authorization")), expected) != 1 {
+			write.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			services.WriteProblem(write, request, apperrors.Unauthorized("authentication_required", "authentication required"))
+			return
+		}
+
+		handler.ServeHTTP(write, request)
+	})
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleOwner  Role = "owner"
+)
+
+var roleRanks = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+func (r Role) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Allows reports whether r grants everything required does: owners can do
+// what editors can, and editors what viewers can.
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[required]
+}
+
+// List is a shared task list as seen by one of its members.
+type List struct {
+	ID        int64
+	Title     string
+	Role      Role
+	CreatedAt time.Time
+}
+
+type ListMember struct {
+	UserID    int64
+	Login     string
+	Role      Role
+	CreatedAt time.Time
+}
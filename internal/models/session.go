@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Session is a sign-in: the refresh token family started by one sign-in
+// and every token rotated from it.
+type Session struct {
+	ID         string
+	UserID     int64
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type RefreshToken struct {
+	TokenHash string
+	FamilyID  string
+	UserID    int64
+	ExpiresAt time.Time
+	Used      bool
+	UsedAt    time.Time
+	Revoked   bool
+}
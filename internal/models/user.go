@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type User struct {
+	ID           int64
+	Login        string
+	PasswordHash string
+	CreatedAt    time.Time
+
+	TOTPSecret   string
+	TOTPEnabled  bool
+	TOTPLastStep int64
+}
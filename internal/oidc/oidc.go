@@ -0,0 +1,317 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = errors.New("invalid id token")
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Claims are the parts of a verified ID token the app cares about.
+type Claims struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	PreferredUsername string
+}
+
+// Provider talks to one OpenID Connect provider. Discovery and key fetching
+// happen lazily so that the server starts even while the provider is down.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Client       *http.Client
+	Now          func() time.Time
+
+	mu       sync.Mutex
+	meta     *metadata
+	keys     map[string]interface{}
+	keysTime time.Time
+}
+
+func NewProvider(issuer string, clientID string, clientSecret string, redirectURL string) *Provider {
+	return &Provider{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		Now:          time.Now,
+	}
+}
+
+// CodeChallenge derives the S256 PKCE challenge for verifier.
+func CodeChallenge(verifier string) string {
+
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (p *Provider) discover() (*metadata, error) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	var meta metadata
+	if err := p.getJSON(p.Issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("discovery error: %w", err)
+	}
+
+	if strings.TrimSuffix(meta.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("discovery error: issuer %q does not match %q", meta.Issuer, p.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("discovery error: incomplete provider metadata")
+	}
+
+	p.meta = &meta
+	return p.meta, nil
+}
+
+func (p *Provider) AuthCodeURL(state string, nonce string, verifier string) (string, error) {
+
+	meta, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", p.RedirectURL)
+	query.Set("scope", "openid email profile")
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return meta.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified ID token
+// claims.
+func (p *Provider) Exchange(code string, verifier string, nonce string) (Claims, error) {
+
+	meta, err := p.discover()
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", verifier)
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	response, err := p.Client.PostForm(meta.TokenEndpoint, form)
+	if err != nil {
+		return Claims{}, fmt.Errorf("token request error: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return Claims{}, fmt.Errorf("token request error: status %d", response.StatusCode)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&tokens); err != nil {
+		return Claims{}, fmt.Errorf("token response decode error: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return Claims{}, errors.New("token response has no id_token")
+	}
+
+	return p.Verify(tokens.IDToken, nonce)
+}
+
+// Verify checks the ID token signature against the provider JWKS and its
+// issuer, audience, expiry and nonce.
+func (p *Provider) Verify(rawToken string, nonce string) (Claims, error) {
+
+	meta, err := p.discover()
+	if err != nil {
+		return Claims{}, err
+	}
+
+	token, err := jwt.Parse(rawToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(meta.JWKSURI, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(p.Now),
+	)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return Claims{}, ErrInvalidToken
+	}
+
+	if tokenNonce, _ := mapClaims["nonce"].(string); tokenNonce != nonce {
+		return Claims{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+
+	claims := Claims{Issuer: meta.Issuer}
+	claims.Subject, _ = mapClaims["sub"].(string)
+	claims.PreferredUsername, _ = mapClaims["preferred_username"].(string)
+	if verified, ok := mapClaims["email_verified"].(bool); !ok || verified {
+		claims.Email, _ = mapClaims["email"].(string)
+	}
+
+	if claims.Subject == "" {
+		return Claims{}, fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// key finds the verification key by kid, refetching the JWKS at most once a
+// minute when the provider has rotated to a key we have not seen.
+func (p *Provider) key(jwksURI string, kid string) (interface{}, error) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	if p.keys != nil && p.Now().Sub(p.keysTime) < time.Minute {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("jwks error: %w", err)
+	}
+
+	p.keys = map[string]interface{}{}
+	p.keysTime = p.Now()
+
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		p.keys[jwk.Kid] = key
+	}
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+
+	decode := func(value string) (*big.Int, error) {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(data), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func (p *Provider) getJSON(address string, value interface{}) error {
+
+	response, err := p.Client.Get(address)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", address, response.StatusCode)
+	}
+
+	return json.NewDecoder(response.Body).Decode(value)
+}
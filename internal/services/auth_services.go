@@ -0,0 +1,138 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	argon2Time    = 1
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+var errInvalidHash = errors.New("invalid password hash")
+
+// dummyHash is compared against when the account does not exist, so that
+// unknown logins take as long to reject as wrong passwords.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+func HashPassword(password string) (string, error) {
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("password hash error: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// HashPasswordArgon2id encodes the hash in the PHC string format:
+// $argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>.
+func HashPasswordArgon2id(password string) (string, error) {
+
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("salt generation error: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func IsPasswordHash(value string) bool {
+
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$", "$argon2id$"} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckPassword verifies a password against a bcrypt or argon2id hash.
+func CheckPassword(hash string, password string) bool {
+
+	if strings.HasPrefix(hash, "$argon2id$") {
+		ok, err := checkArgon2id(hash, password)
+		return err == nil && ok
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// RejectPassword spends the same time as a failed CheckPassword.
+func RejectPassword(password string) {
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// CheckPlainPassword compares against a plaintext secret in constant time.
+func CheckPlainPassword(expected string, password string) bool {
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1
+}
+
+func checkArgon2id(hash string, password string) (bool, error) {
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, errInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, errInvalidHash
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, errInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, errInvalidHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, errInvalidHash
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, actual) == 1, nil
+}
+
+// RandomToken returns size random bytes encoded for use in URLs and headers.
+func RandomToken(size int) (string, error) {
+
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("random token error: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// HashToken is used to store high-entropy tokens, which need no salt or
+// slow hashing, without keeping them in plaintext.
+func HashToken(token string) string {
+
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,57 @@
+package services
+
+import "strings"
+
+// DeviceName gives a short human-readable description of a User-Agent, such
+// as "Firefox, Windows". It only needs to be good enough for people to
+// recognise their own sessions.
+func DeviceName(userAgent string) string {
+
+	if userAgent == "" {
+		return "unknown device"
+	}
+
+	browsers := []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"YaBrowser/", "Yandex Browser"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"Go-http-client/", "Go HTTP client"},
+	}
+
+	systems := []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+
+	var parts []string
+	for _, browser := range browsers {
+		if strings.Contains(userAgent, browser.token) {
+			parts = append(parts, browser.name)
+			break
+		}
+	}
+	for _, system := range systems {
+		if strings.Contains(userAgent, system.token) {
+			parts = append(parts, system.name)
+			break
+		}
+	}
+
+	if len(parts) == 0 {
+		if name, _, _ := strings.Cut(userAgent, " "); len(name) <= 64 {
+			return name
+		}
+		return "unknown device"
+	}
+
+	return strings.Join(parts, ", ")
+}
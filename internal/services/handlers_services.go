@@ -2,14 +2,14 @@ package services
 
 import (
 	"encoding/json"
-	"errors"
-	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"todo_restapi/internal/apperrors"
 	"todo_restapi/internal/constants"
 	"todo_restapi/internal/models"
 )
@@ -17,12 +17,12 @@ import (
 func NextDate(now time.Time, date string, repeat string) (string, error) {
 
 	if repeat == "" {
-		return "", errors.New("repeat cannot be empty")
+		return "", invalidField("repeat", "required", "repeat cannot be empty")
 	}
 
 	dateParse, err := time.Parse(constants.DateFormat, date)
 	if err != nil {
-		return "", fmt.Errorf("date parse error: %w", err)
+		return "", invalidField("date", "invalid_format", "invalid date format").Wrap(err)
 	}
 
 	repeatType, firstRepeatPattern, _ := parseRepeat(repeat)
@@ -32,11 +32,11 @@ func NextDate(now time.Time, date string, repeat string) (string, error) {
 	case "d":
 
 		if len(firstRepeatPattern) == 0 {
-			return "", errors.New("\"d\" parameter is empty")
+			return "", invalidField("repeat", "invalid", "\"d\" parameter is empty")
 		}
 
 		if firstRepeatPattern[0] > 400 {
-			return "", errors.New("invalid \"d\" value (400 is max)")
+			return "", invalidField("repeat", "out_of_range", "invalid \"d\" value (400 is max)")
 		}
 
 		dateParse = dateParse.AddDate(0, 0, firstRepeatPattern[0])
@@ -56,7 +56,7 @@ func NextDate(now time.Time, date string, repeat string) (string, error) {
 		return dateParse.Format(constants.DateFormat), nil
 
 	default:
-		return "", errors.New("invalid repeat value")
+		return "", invalidField("repeat", "invalid", "invalid repeat value")
 	}
 }
 
@@ -68,7 +68,7 @@ func parseNumbers(input string) []int {
 	for _, num := range stringNums {
 		intNum, err := strconv.Atoi(num)
 		if err != nil {
-			fmt.Println("parseNumbers: string to int conversion error")
+			slog.Debug("parseNumbers: string to int conversion error", "value", num)
 		}
 		output = append(output, intNum)
 	}
@@ -98,7 +98,7 @@ func parseRepeat(repeat string) (string, []int, []int) {
 func ValidateTaskRequest(newTask *models.Task, now string) error {
 
 	if newTask.Title == "" {
-		return errors.New("title is empty")
+		return invalidField("title", "required", "title is empty")
 	}
 
 	if newTask.Date == "" {
@@ -107,7 +107,7 @@ func ValidateTaskRequest(newTask *models.Task, now string) error {
 
 	_, err := time.Parse(constants.DateFormat, newTask.Date)
 	if err != nil {
-		return errors.New("invalid date format")
+		return invalidField("date", "invalid_format", "invalid date format").Wrap(err)
 	}
 
 	if newTask.Date < now {
@@ -116,7 +116,7 @@ func ValidateTaskRequest(newTask *models.Task, now string) error {
 		} else {
 			dateCalculation, err := NextDate(time.Now(), newTask.Date, newTask.Repeat)
 			if err != nil {
-				return fmt.Errorf("NextDate: function error: %w", err)
+				return err
 			}
 			newTask.Date = dateCalculation
 		}
@@ -124,16 +124,54 @@ func ValidateTaskRequest(newTask *models.Task, now string) error {
 	return nil
 }
 
-func WriteJSONError(write http.ResponseWriter, statusCode int, errMsg string) {
+func invalidField(field string, code string, message string) *apperrors.Error {
+	return apperrors.Validation("validation_failed", message, apperrors.Field(field, code, message))
+}
+
+type problem struct {
+	Type     string                 `json:"type"`
+	Title    string                 `json:"title"`
+	Status   int                    `json:"status"`
+	Detail   string                 `json:"detail"`
+	Instance string                 `json:"instance,omitempty"`
+	Code     string                 `json:"code"`
+	Errors   []apperrors.FieldError `json:"errors,omitempty"`
+	// Error duplicates Detail for clients written against the old {"error": ...} shape.
+	Error string `json:"error"`
+}
+
+// WriteProblem writes err as an RFC 7807 problem. Untyped errors become a
+// generic 500 so that internal details only end up in the log.
+func WriteProblem(write http.ResponseWriter, request *http.Request, err error) {
 
-	log.Println(errMsg)
+	appErr := apperrors.From(err)
+	status := appErr.Kind.Status()
 
-	write.Header().Set("Content-Type", "application/json")
-	write.WriteHeader(statusCode)
+	level := slog.LevelDebug
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	slog.Log(request.Context(), level, "request failed", "status", status, "code", appErr.Code, "error", err)
+
+	response := problem{
+		Type:     "/problems/" + appErr.Code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   appErr.Message,
+		Instance: request.URL.Path,
+		Code:     appErr.Code,
+		Errors:   appErr.Fields,
+		Error:    appErr.Message,
+	}
+
+	if appErr.RetryAfter > 0 {
+		write.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+	}
 
-	response := map[string]string{"error": errMsg}
+	write.Header().Set("Content-Type", "application/problem+json")
+	write.WriteHeader(status)
 
 	if err := json.NewEncoder(write).Encode(response); err != nil {
-		log.Printf("failed to encode JSON response: %v", err)
+		slog.ErrorContext(request.Context(), "failed to encode problem response", "error", err)
 	}
 }
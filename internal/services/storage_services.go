@@ -9,10 +9,11 @@ import (
 
 func IsDate(searchQuery string) (string, error) {
 
-	isTime, err := time.Parse("02.01.2006", searchQuery)
-	if err != nil {
-		return "", errors.New("invalid date format")
+	for _, layout := range []string{"02.01.2006", constants.ISODateFormat} {
+		if isTime, err := time.Parse(layout, searchQuery); err == nil {
+			return isTime.Format(constants.DateFormat), nil
+		}
 	}
 
-	return isTime.Format(constants.DateFormat), nil
+	return "", errors.New("invalid date format")
 }
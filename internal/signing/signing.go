@@ -0,0 +1,277 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSABits = 2048
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key signs or verifies tokens with one algorithm. Asymmetric keys are
+// identified by their RFC 7638 thumbprint, which is sent as the kid header.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	private interface{}
+	public  interface{}
+}
+
+// CanSign reports whether the key holds private material.
+func (k *Key) CanSign() bool {
+	return k.private != nil
+}
+
+// JSONWebKey is the public half of a key as published in the JWKS.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// KeySet holds the active signing key and every key tokens may still be
+// verified with, so that keys can be rotated without signing everyone out.
+type KeySet struct {
+	active *Key
+	keys   []*Key
+}
+
+// NewHMACKeySet keeps the shared-secret HS256 setup. Its tokens carry no kid
+// and nothing is published in the JWKS.
+func NewHMACKeySet(secret string) *KeySet {
+
+	key := &Key{Method: jwt.SigningMethodHS256, private: []byte(secret), public: []byte(secret)}
+	return &KeySet{active: key, keys: []*Key{key}}
+}
+
+// LoadKeySet reads PEM files: the first one signs new tokens and must hold a
+// private key, the rest are only used to verify tokens issued before a
+// rotation and may hold public keys.
+func LoadKeySet(paths []string) (*KeySet, error) {
+
+	if len(paths) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+
+	set := &KeySet{}
+
+	for i, path := range paths {
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("key file read error: %w", err)
+		}
+
+		key, err := ParseKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("key file %s: %w", path, err)
+		}
+
+		if i == 0 {
+			if !key.CanSign() {
+				return nil, fmt.Errorf("key file %s: the active signing key must be a private key", path)
+			}
+			set.active = key
+		}
+
+		set.keys = append(set.keys, key)
+	}
+
+	return set, nil
+}
+
+// ParseKey accepts RSA and Ed25519 keys in PKCS#8, PKCS#1 or PKIX PEM blocks.
+func ParseKey(data []byte) (*Key, error) {
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key parse error: %w", err)
+	}
+
+	key := &Key{}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", parsed)
+	}
+
+	if public, ok := key.public.(*rsa.PublicKey); ok && public.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+	}
+
+	jwk := key.JWK()
+	key.ID = jwk.Kid
+
+	return key, nil
+}
+
+// GenerateKey creates a private key for alg ("RS256" or "EdDSA") and returns
+// it as a PKCS#8 PEM block.
+func GenerateKey(alg string) ([]byte, error) {
+
+	var private interface{}
+	var err error
+
+	switch alg {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, minRSABits)
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, use RS256 or EdDSA", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key generation error: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("key encoding error: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// JWK returns the public key with its RFC 7638 thumbprint as kid. It is empty
+// for HMAC keys.
+func (k *Key) JWK() JSONWebKey {
+
+	encode := base64.RawURLEncoding.EncodeToString
+	var jwk JSONWebKey
+	var canonical []byte
+
+	switch public := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk = JSONWebKey{Kty: "RSA", N: encode(public.N.Bytes()), E: encode(big.NewInt(int64(public.E)).Bytes())}
+		canonical, _ = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N})
+	case ed25519.PublicKey:
+		jwk = JSONWebKey{Kty: "OKP", Crv: "Ed25519", X: encode(public)}
+		canonical, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X})
+	default:
+		return JSONWebKey{}
+	}
+
+	thumbprint := sha256.Sum256(canonical)
+	jwk.Kid = encode(thumbprint[:])
+	jwk.Use = "sig"
+	jwk.Alg = k.Method.Alg()
+
+	return jwk
+}
+
+// Sign signs claims with the active key.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+
+	token := jwt.NewWithClaims(s.active.Method, claims)
+	if s.active.ID != "" {
+		token.Header["kid"] = s.active.ID
+	}
+
+	return token.SignedString(s.active.private)
+}
+
+// Keyfunc finds the verification key by kid and refuses tokens whose alg does
+// not match it, so a public key can never be used as an HMAC secret.
+func (s *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+
+	kid, _ := token.Header["kid"].(string)
+
+	for _, key := range s.keys {
+		if key.ID == kid {
+			if token.Method.Alg() != key.Method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+			return key.public, nil
+		}
+	}
+
+	return nil, fmt.Errorf("kid %q: %w", kid, ErrUnknownKey)
+}
+
+// Methods lists the algorithms of the set for jwt.WithValidMethods.
+func (s *KeySet) Methods() []string {
+
+	var methods []string
+	seen := map[string]bool{}
+
+	for _, key := range s.keys {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+
+	return methods
+}
+
+// JWKS returns the public keys for /.well-known/jwks.json.
+func (s *KeySet) JWKS() JSONWebKeySet {
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+
+	for _, key := range s.keys {
+		if jwk := key.JWK(); jwk.Kid != "" {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	return set
+}
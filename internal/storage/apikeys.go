@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"todo_restapi/internal/models"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+const apiKeyColumns = "id, user_id, name, prefix, scopes, created_at, last_used_at"
+
+func (s *Storage) AddAPIKey(ctx context.Context, key models.APIKey, keyHash string) (_ int64, err error) {
+
+	ctx, end := s.observe(ctx, "AddAPIKey")
+	defer func() { end(err) }()
+
+	result, err := s.q.ExecContext(ctx, `INSERT INTO api_keys(user_id, name, prefix, key_hash, scopes, created_at)
+		VALUES(?, ?, ?, ?, ?, ?)`,
+		key.UserID, key.Name, key.Prefix, keyHash, strings.Join(key.Scopes, " "), time.Now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("execution error: %w", err)
+	}
+
+	keyID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("getting ID error: %w", err)
+	}
+	return keyID, nil
+}
+
+func (s *Storage) GetAPIKeys(ctx context.Context, userID int64) (_ []models.APIKey, err error) {
+
+	ctx, end := s.observe(ctx, "GetAPIKeys")
+	defer func() { end(err) }()
+
+	rows, err := s.q.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id=? AND revoked_at IS NULL ORDER BY id",
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (s *Storage) GetAPIKey(ctx context.Context, userID int64, keyID int64) (_ models.APIKey, err error) {
+
+	ctx, end := s.observe(ctx, "GetAPIKey")
+	defer func() { end(err) }()
+
+	row := s.q.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id=? AND user_id=? AND revoked_at IS NULL",
+		keyID, userID)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return key, fmt.Errorf("api key with id %d: %w", keyID, ErrAPIKeyNotFound)
+	}
+	return key, err
+}
+
+func (s *Storage) GetAPIKeyByHash(ctx context.Context, keyHash string) (_ models.APIKey, err error) {
+
+	ctx, end := s.observe(ctx, "GetAPIKeyByHash")
+	defer func() { end(err) }()
+
+	row := s.q.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash=? AND revoked_at IS NULL", keyHash)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return key, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// TouchAPIKey records the key as used, at most once a minute, so that scripts
+// hammering the API do not turn every read into a write.
+func (s *Storage) TouchAPIKey(ctx context.Context, keyID int64, now time.Time) (err error) {
+
+	ctx, end := s.observe(ctx, "TouchAPIKey")
+	defer func() { end(err) }()
+
+	_, err = s.q.ExecContext(ctx, "UPDATE api_keys SET last_used_at=? WHERE id=? AND (last_used_at IS NULL OR last_used_at < ?)",
+		now.Unix(), keyID, now.Add(-time.Minute).Unix())
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, userID int64, keyID int64) (err error) {
+
+	ctx, end := s.observe(ctx, "RevokeAPIKey")
+	defer func() { end(err) }()
+
+	result, err := s.q.ExecContext(ctx, "UPDATE api_keys SET revoked_at=? WHERE id=? AND user_id=? AND revoked_at IS NULL",
+		time.Now().Unix(), keyID, userID)
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected error: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("api key with id %d: %w", keyID, ErrAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+
+	var key models.APIKey
+	var scopes string
+	var createdAt int64
+	var lastUsedAt sql.NullInt64
+
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &scopes, &createdAt, &lastUsedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return key, err
+		}
+		return key, fmt.Errorf("scan error: %w", err)
+	}
+
+	key.Scopes = strings.Fields(scopes)
+	key.CreatedAt = time.Unix(createdAt, 0).UTC()
+	if lastUsedAt.Valid {
+		lastUsed := time.Unix(lastUsedAt.Int64, 0).UTC()
+		key.LastUsedAt = &lastUsed
+	}
+
+	return key, nil
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Backup writes a consistent copy of the database to path with VACUUM INTO,
+// which works while the server keeps serving. path must not exist yet.
+func (s *Storage) Backup(ctx context.Context, path string) (err error) {
+
+	ctx, end := s.observe(ctx, "Backup")
+	defer func() { end(err) }()
+
+	if _, err := s.q.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("vacuum into error: %w", err)
+	}
+
+	return nil
+}
+
+// Restore replaces the database file at storagePath with the backup at path.
+// The backup is copied next to the database and opened first, which applies
+// pending migrations and rejects files that are not a database, and only
+// then renamed over it. The server must not be running.
+func Restore(path string, storagePath string) error {
+
+	source, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("backup open error: %w", err)
+	}
+	defer source.Close()
+
+	staging := storagePath + ".restore"
+	target, err := os.OpenFile(staging, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("staging file error: %w", err)
+	}
+	defer os.Remove(staging)
+
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		return fmt.Errorf("copy error: %w", err)
+	}
+
+	if err := target.Close(); err != nil {
+		return fmt.Errorf("copy error: %w", err)
+	}
+
+	restored, err := OpenStorage(staging)
+	if err != nil {
+		return fmt.Errorf("OpenStorage: function error: %w", err)
+	}
+
+	err = restored.Ping(context.Background())
+	if closeErr := restored.CloseStorage(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("backup check error: %w", err)
+	}
+
+	// A journal left by the old database would be replayed into the new one.
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		if err := os.Remove(storagePath + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("journal remove error: %w", err)
+		}
+	}
+
+	if err := os.Rename(staging, storagePath); err != nil {
+		return fmt.Errorf("rename error: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"todo_restapi/internal/models"
+)
+
+// ReserveIdempotencyKey claims key for a new request. If the key is already
+// known, the stored record is returned with reserved set to false.
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, key string, requestHash string, ttl time.Duration) (_ models.IdempotencyRecord, _ bool, err error) {
+
+	ctx, end := s.observe(ctx, "ReserveIdempotencyKey")
+	defer func() { end(err) }()
+
+	var record models.IdempotencyRecord
+	reserved := false
+	now := time.Now()
+
+	err = s.WithTx(ctx, func(tx *Storage) error {
+
+		if _, err := tx.q.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE created_at < ?", now.Add(-ttl).Unix()); err != nil {
+			return fmt.Errorf("expired keys cleanup error: %w", err)
+		}
+
+		var createdAt int64
+		row := tx.q.QueryRowContext(ctx, "SELECT key, request_hash, status, content_type, body, created_at FROM idempotency_keys WHERE key=?", key)
+
+		err := row.Scan(&record.Key, &record.RequestHash, &record.StatusCode, &record.ContentType, &record.Body, &createdAt)
+		if err == nil {
+			record.CreatedAt = time.Unix(createdAt, 0)
+			return nil
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("scan error: %w", err)
+		}
+
+		_, err = tx.q.ExecContext(ctx, "INSERT INTO idempotency_keys(key, request_hash, created_at) VALUES(?, ?, ?)", key, requestHash, now.Unix())
+		if err != nil {
+			return fmt.Errorf("execution error: %w", err)
+		}
+
+		record = models.IdempotencyRecord{Key: key, RequestHash: requestHash, CreatedAt: now}
+		reserved = true
+		return nil
+	})
+	if err != nil {
+		return record, false, err
+	}
+
+	return record, reserved, nil
+}
+
+func (s *Storage) SaveIdempotencyResponse(ctx context.Context, record models.IdempotencyRecord) (err error) {
+
+	ctx, end := s.observe(ctx, "SaveIdempotencyResponse")
+	defer func() { end(err) }()
+
+	_, err = s.q.ExecContext(ctx, "UPDATE idempotency_keys SET status=?, content_type=?, body=? WHERE key=?",
+		record.StatusCode, record.ContentType, record.Body, record.Key)
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteIdempotencyKey(ctx context.Context, key string) (err error) {
+
+	ctx, end := s.observe(ctx, "DeleteIdempotencyKey")
+	defer func() { end(err) }()
+
+	if _, err := s.q.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key=?", key); err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	return nil
+}
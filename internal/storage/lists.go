@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"todo_restapi/internal/models"
+)
+
+var (
+	ErrListNotFound   = errors.New("list not found")
+	ErrMemberNotFound = errors.New("list member not found")
+	ErrMemberExists   = errors.New("list member already exists")
+	ErrLastOwner      = errors.New("list must keep an owner")
+)
+
+// CreateList creates a list owned by ownerID.
+func (s *Storage) CreateList(ctx context.Context, ownerID int64, title string) (_ int64, err error) {
+
+	ctx, end := s.observe(ctx, "CreateList")
+	defer func() { end(err) }()
+
+	var listID int64
+
+	err = s.WithTx(ctx, func(tx *Storage) error {
+
+		now := time.Now().Unix()
+
+		result, err := tx.q.ExecContext(ctx, "INSERT INTO lists(title, created_at) VALUES(?, ?)", title, now)
+		if err != nil {
+			return fmt.Errorf("execution error: %w", err)
+		}
+
+		listID, err = result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("getting ID error: %w", err)
+		}
+
+		return tx.AddListMember(ctx, listID, ownerID, models.RoleOwner)
+	})
+
+	return listID, err
+}
+
+const listColumns = "lists.id, lists.title, list_members.role, lists.created_at"
+
+// GetLists returns the lists userID is a member of, with their role in each.
+func (s *Storage) GetLists(ctx context.Context, userID int64) (_ []models.List, err error) {
+
+	ctx, end := s.observe(ctx, "GetLists")
+	defer func() { end(err) }()
+
+	rows, err := s.q.QueryContext(ctx, "SELECT "+listColumns+` FROM lists
+		JOIN list_members ON list_members.list_id = lists.id
+		WHERE list_members.user_id=? ORDER BY lists.id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	lists := []models.List{}
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+		lists = append(lists, list)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return lists, nil
+}
+
+// GetList returns the list as seen by userID. Lists the user is not a member
+// of are reported as missing.
+func (s *Storage) GetList(ctx context.Context, userID int64, listID int64) (_ models.List, err error) {
+
+	ctx, end := s.observe(ctx, "GetList")
+	defer func() { end(err) }()
+
+	row := s.q.QueryRowContext(ctx, "SELECT "+listColumns+` FROM lists
+		JOIN list_members ON list_members.list_id = lists.id
+		WHERE lists.id=? AND list_members.user_id=?`, listID, userID)
+
+	list, err := scanList(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return list, fmt.Errorf("list with id %d: %w", listID, ErrListNotFound)
+	} else if err != nil {
+		return list, fmt.Errorf("scan error: %w", err)
+	}
+
+	return list, nil
+}
+
+func scanList(row rowScanner) (models.List, error) {
+
+	var list models.List
+	var role string
+	var createdAt int64
+
+	if err := row.Scan(&list.ID, &list.Title, &role, &createdAt); err != nil {
+		return list, err
+	}
+
+	list.Role = models.Role(role)
+	list.CreatedAt = time.Unix(createdAt, 0).UTC()
+
+	return list, nil
+}
+
+// DeleteList removes the list together with its tasks and members.
+func (s *Storage) DeleteList(ctx context.Context, listID int64) (err error) {
+
+	ctx, end := s.observe(ctx, "DeleteList")
+	defer func() { end(err) }()
+
+	return s.WithTx(ctx, func(tx *Storage) error {
+
+		for _, statement := range []string{
+			"DELETE FROM scheduler WHERE list_id=?",
+			"DELETE FROM list_members WHERE list_id=?",
+		} {
+			if _, err := tx.q.ExecContext(ctx, statement, listID); err != nil {
+				return fmt.Errorf("execution error: %w", err)
+			}
+		}
+
+		result, err := tx.q.ExecContext(ctx, "DELETE FROM lists WHERE id=?", listID)
+		if err != nil {
+			return fmt.Errorf("execution error: %w", err)
+		}
+
+		if affected, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("rows affected error: %w", err)
+		} else if affected == 0 {
+			return fmt.Errorf("list with id %d: %w", listID, ErrListNotFound)
+		}
+
+		return nil
+	})
+}
+
+// ListRole returns the role of userID in the list, or ErrListNotFound when
+// the user is not a member.
+func (s *Storage) ListRole(ctx context.Context, listID int64, userID int64) (_ models.Role, err error) {
+
+	ctx, end := s.observe(ctx, "ListRole")
+	defer func() { end(err) }()
+
+	var role string
+
+	err = s.q.QueryRowContext(ctx, "SELECT role FROM list_members WHERE list_id=? AND user_id=?", listID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("list with id %d: %w", listID, ErrListNotFound)
+	} else if err != nil {
+		return "", fmt.Errorf("scan error: %w", err)
+	}
+
+	return models.Role(role), nil
+}
+
+func (s *Storage) GetListMembers(ctx context.Context, listID int64) (_ []models.ListMember, err error) {
+
+	ctx, end := s.observe(ctx, "GetListMembers")
+	defer func() { end(err) }()
+
+	rows, err := s.q.QueryContext(ctx, `SELECT list_members.user_id, users.login, list_members.role, list_members.created_at
+		FROM list_members JOIN users ON users.id = list_members.user_id
+		WHERE list_members.list_id=? ORDER BY list_members.created_at, list_members.user_id`, listID)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	members := []models.ListMember{}
+	for rows.Next() {
+
+		var member models.ListMember
+		var role string
+		var createdAt int64
+
+		if err := rows.Scan(&member.UserID, &member.Login, &role, &createdAt); err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		member.Role = models.Role(role)
+		member.CreatedAt = time.Unix(createdAt, 0).UTC()
+		members = append(members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return members, nil
+}
+
+func (s *Storage) AddListMember(ctx context.Context, listID int64, userID int64, role models.Role) (err error) {
+
+	ctx, end := s.observe(ctx, "AddListMember")
+	defer func() { end(err) }()
+
+	result, err := s.q.ExecContext(ctx, `INSERT INTO list_members(list_id, user_id, role, created_at) VALUES(?, ?, ?, ?)
+		ON CONFLICT(list_id, user_id) DO NOTHING`, listID, userID, string(role), time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("rows affected error: %w", err)
+	} else if affected == 0 {
+		return fmt.Errorf("user %d in list %d: %w", userID, listID, ErrMemberExists)
+	}
+
+	return nil
+}
+
+// SetListMemberRole changes the role of a member. The last owner cannot be
+// demoted, so that every list stays manageable.
+func (s *Storage) SetListMemberRole(ctx context.Context, listID int64, userID int64, role models.Role) (err error) {
+
+	ctx, end := s.observe(ctx, "SetListMemberRole")
+	defer func() { end(err) }()
+
+	return s.WithTx(ctx, func(tx *Storage) error {
+
+		if role != models.RoleOwner {
+			if err := tx.checkNotLastOwner(ctx, listID, userID); err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.q.ExecContext(ctx, "UPDATE list_members SET role=? WHERE list_id=? AND user_id=?",
+			string(role), listID, userID)
+		if err != nil {
+			return fmt.Errorf("execution error: %w", err)
+		}
+
+		if affected, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("rows affected error: %w", err)
+		} else if affected == 0 {
+			return fmt.Errorf("user %d in list %d: %w", userID, listID, ErrMemberNotFound)
+		}
+
+		return nil
+	})
+}
+
+// RemoveListMember takes userID out of the list. Tasks the member created
+// stay in the list.
+func (s *Storage) RemoveListMember(ctx context.Context, listID int64, userID int64) (err error) {
+
+	ctx, end := s.observe(ctx, "RemoveListMember")
+	defer func() { end(err) }()
+
+	return s.WithTx(ctx, func(tx *Storage) error {
+
+		if err := tx.checkNotLastOwner(ctx, listID, userID); err != nil {
+			return err
+		}
+
+		result, err := tx.q.ExecContext(ctx, "DELETE FROM list_members WHERE list_id=? AND user_id=?", listID, userID)
+		if err != nil {
+			return fmt.Errorf("execution error: %w", err)
+		}
+
+		if affected, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("rows affected error: %w", err)
+		} else if affected == 0 {
+			return fmt.Errorf("user %d in list %d: %w", userID, listID, ErrMemberNotFound)
+		}
+
+		return nil
+	})
+}
+
+func (s *Storage) checkNotLastOwner(ctx context.Context, listID int64, userID int64) error {
+
+	var owners int
+	var isOwner bool
+
+	err := s.q.QueryRowContext(ctx, `SELECT COUNT(*), COALESCE(MAX(user_id=?), 0) FROM list_members
+		WHERE list_id=? AND role=?`, userID, listID, string(models.RoleOwner)).Scan(&owners, &isOwner)
+	if err != nil {
+		return fmt.Errorf("scan error: %w", err)
+	}
+
+	if isOwner && owners == 1 {
+		return fmt.Errorf("user %d in list %d: %w", userID, listID, ErrLastOwner)
+	}
+
+	return nil
+}
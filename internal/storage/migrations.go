@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type migration struct {
+	version    int
+	statements []string
+}
+
+// Migrations are applied in order and recorded in schema_migrations. The
+// first ones use IF NOT EXISTS so that databases created before versioning
+// was introduced are picked up without changes.
+var migrations = []migration{
+	{
+		version: 1,
+		statements: []string{`
+		CREATE TABLE IF NOT EXISTS scheduler (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date CHAR(8) NOT NULL DEFAULT '',
+			title TEXT NOT NULL DEFAULT '',
+			comment TEXT NOT NULL DEFAULT '',
+			repeat VARCHAR(128) NOT NULL DEFAULT '');`,
+			`CREATE INDEX IF NOT EXISTS scheduler_date on scheduler(date);`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{`
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			key TEXT PRIMARY KEY,
+			request_hash CHAR(64) NOT NULL,
+			status INTEGER NOT NULL DEFAULT 0,
+			content_type TEXT NOT NULL DEFAULT '',
+			body BLOB,
+			created_at INTEGER NOT NULL);`,
+		},
+	},
+	{
+		version: 3,
+		statements: []string{
+			`ALTER TABLE scheduler ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0;`,
+			`ALTER TABLE scheduler ADD COLUMN updated_at INTEGER NOT NULL DEFAULT 0;`,
+			`UPDATE scheduler SET created_at = strftime('%s', 'now'), updated_at = strftime('%s', 'now');`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{`
+		CREATE TABLE users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			login TEXT NOT NULL UNIQUE,
+			password_hash TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL);`,
+			// The built-in admin signs in with the configured password and
+			// owns every task created before accounts existed.
+			`INSERT INTO users(id, login, password_hash, created_at) VALUES (1, 'admin', '', strftime('%s', 'now'));`,
+			`ALTER TABLE scheduler ADD COLUMN owner_id INTEGER NOT NULL DEFAULT 1;`,
+			`CREATE INDEX scheduler_owner_date ON scheduler(owner_id, date);`,
+		},
+	},
+	{
+		version: 5,
+		statements: []string{`
+		CREATE TABLE token_families (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id),
+			created_at INTEGER NOT NULL,
+			revoked_at INTEGER);`, `
+		CREATE TABLE refresh_tokens (
+			token_hash CHAR(64) PRIMARY KEY,
+			family_id TEXT NOT NULL REFERENCES token_families(id),
+			expires_at INTEGER NOT NULL,
+			used_at INTEGER);`,
+			`CREATE INDEX refresh_tokens_family ON refresh_tokens(family_id);`, `
+		CREATE TABLE revoked_tokens (
+			jti TEXT PRIMARY KEY,
+			expires_at INTEGER NOT NULL);`,
+		},
+	},
+	{
+		version: 6,
+		statements: []string{`
+		CREATE TABLE api_keys (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL REFERENCES users(id),
+			name TEXT NOT NULL DEFAULT '',
+			prefix TEXT NOT NULL,
+			key_hash CHAR(64) NOT NULL UNIQUE,
+			scopes TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL,
+			last_used_at INTEGER,
+			revoked_at INTEGER);`,
+			`CREATE INDEX api_keys_user ON api_keys(user_id);`,
+		},
+	},
+	{
+		version: 7,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';`,
+			`ALTER TABLE users ADD COLUMN totp_enabled INTEGER NOT NULL DEFAULT 0;`,
+			`ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;`, `
+		CREATE TABLE recovery_codes (
+			user_id INTEGER NOT NULL REFERENCES users(id),
+			code_hash CHAR(64) NOT NULL,
+			PRIMARY KEY (user_id, code_hash));`,
+		},
+	},
+	{
+		version: 8,
+		statements: []string{`
+		CREATE TABLE user_identities (
+			issuer TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			user_id INTEGER NOT NULL REFERENCES users(id),
+			created_at INTEGER NOT NULL,
+			PRIMARY KEY (issuer, subject));`,
+		},
+	},
+	{
+		version: 9,
+		statements: []string{`
+		CREATE TABLE lists (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			title TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL);`, `
+		CREATE TABLE list_members (
+			list_id INTEGER NOT NULL REFERENCES lists(id),
+			user_id INTEGER NOT NULL REFERENCES users(id),
+			role TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			PRIMARY KEY (list_id, user_id));`,
+			`CREATE INDEX list_members_user ON list_members(user_id);`,
+			// Tasks without a list stay private to their owner.
+			`ALTER TABLE scheduler ADD COLUMN list_id INTEGER REFERENCES lists(id);`,
+			`CREATE INDEX scheduler_list_date ON scheduler(list_id, date);`,
+		},
+	},
+	{
+		version: 10,
+		statements: []string{
+			// A token family is a sign-in session; remember where it started.
+			`ALTER TABLE token_families ADD COLUMN ip TEXT NOT NULL DEFAULT '';`,
+			`ALTER TABLE token_families ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';`,
+			`ALTER TABLE token_families ADD COLUMN last_seen_at INTEGER NOT NULL DEFAULT 0;`,
+			`UPDATE token_families SET last_seen_at = created_at;`,
+			`CREATE INDEX token_families_user ON token_families(user_id);`,
+		},
+	},
+}
+
+func migrate(ctx context.Context, db *sql.DB) error {
+
+	_, err := db.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')));
+	`)
+	if err != nil {
+		return fmt.Errorf("migrations table create error: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("schema version query error: %w", err)
+	}
+
+	for _, m := range migrations {
+
+		if m.version <= current {
+			continue
+		}
+
+		err := NewStorage(db).WithTx(ctx, func(tx *Storage) error {
+			for _, statement := range m.statements {
+				if _, err := tx.q.ExecContext(ctx, statement); err != nil {
+					return fmt.Errorf("execution error: %w", err)
+				}
+			}
+
+			if _, err := tx.q.ExecContext(ctx, "INSERT INTO schema_migrations(version) VALUES(?)", m.version); err != nil {
+				return fmt.Errorf("version record error: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d error: %w", m.version, err)
+		}
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the applied schema version and the latest one this
+// build knows about.
+func (s *Storage) SchemaVersion(ctx context.Context) (_ int, _ int, err error) {
+
+	ctx, end := s.observe(ctx, "SchemaVersion")
+	defer func() { end(err) }()
+
+	latest := migrations[len(migrations)-1].version
+
+	var current int
+	if err := s.q.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return 0, latest, fmt.Errorf("schema version query error: %w", err)
+	}
+
+	return current, latest, nil
+}
@@ -1,32 +1,180 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	_ "modernc.org/sqlite"
 	"todo_restapi/internal/constants"
+	"todo_restapi/internal/metrics"
 	"todo_restapi/internal/models"
 	"todo_restapi/internal/services"
 )
 
+var (
+	ErrNotFound  = errors.New("task not found")
+	ErrInvalidID = errors.New("invalid task id")
+)
+
+const (
+	tracerName = "todo_restapi/internal/storage"
+
+	DefaultQueryTimeout = 5 * time.Second
+)
+
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
 type Storage struct {
-	db *sql.DB
+	db      *sql.DB
+	q       queryer
+	timeout time.Duration
 }
 
 func NewStorage(db *sql.DB) *Storage {
-	return &Storage{db: db}
+	return &Storage{db: db, q: db, timeout: DefaultQueryTimeout}
+}
+
+// SetQueryTimeout limits how long a single storage method may run; zero
+// leaves only the caller's context in charge.
+func (s *Storage) SetQueryTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+// WithTx runs fn inside a single transaction, committing when fn returns nil.
+// A Storage that is already bound to a transaction reuses it. The
+// transaction is rolled back if ctx is done before it commits.
+func (s *Storage) WithTx(ctx context.Context, fn func(tx *Storage) error) error {
+
+	if _, ok := s.q.(*sql.Tx); ok {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction begin error: %w", err)
+	}
+
+	if err := fn(&Storage{db: s.db, q: tx, timeout: s.timeout}); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("transaction rollback error: %v: %w", rollbackErr, err)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("transaction commit error: %w", err)
+	}
+
+	return nil
+}
+
+// observe starts a span for a storage method, bounds it by the query
+// timeout and records its duration when the returned function is called
+// with the method's error. Not-found errors are answers, not failures, and
+// leave the span status alone.
+func (s *Storage) observe(ctx context.Context, method string) (context.Context, func(err error)) {
+
+	start := time.Now()
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "storage."+method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "sqlite"), attribute.String("db.operation.name", method)))
+
+	cancel := context.CancelFunc(func() {})
+	if s.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+	}
+
+	return ctx, func(err error) {
+		if err != nil && !isNotFound(err) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		cancel()
+		metrics.ObserveQuery(method, start)
+		span.End()
+	}
+}
+
+// isNotFound reports whether err is one of the not-found errors of the
+// storage methods.
+func isNotFound(err error) bool {
+
+	for _, target := range []error{ErrNotFound, ErrListNotFound, ErrMemberNotFound, ErrUserNotFound,
+		ErrTokenNotFound, ErrSessionNotFound, ErrAPIKeyNotFound, sql.ErrNoRows} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (s *Storage) CloseStorage() error {
 	return s.db.Close()
 }
 
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// CheckWritable creates and removes a file next to the database, which
+// catches a full or read-only volume before SQLite runs into it.
+func (s *Storage) CheckWritable(ctx context.Context) error {
+
+	var seq int
+	var name, file string
+	if err := s.q.QueryRowContext(ctx, "PRAGMA database_list").Scan(&seq, &name, &file); err != nil {
+		return fmt.Errorf("database list error: %w", err)
+	}
+
+	if file == "" {
+		return nil
+	}
+
+	probe, err := os.CreateTemp(filepath.Dir(file), ".writable-*")
+	if err != nil {
+		return fmt.Errorf("create error: %w", err)
+	}
+	defer os.Remove(probe.Name())
+
+	if _, err := probe.WriteString("ok"); err != nil {
+		probe.Close()
+		return fmt.Errorf("write error: %w", err)
+	}
+
+	if err := probe.Close(); err != nil {
+		return fmt.Errorf("close error: %w", err)
+	}
+
+	return nil
+}
+
 func OpenStorage(storagePath string) (*Storage, error) {
 
-	db, err := sql.Open("sqlite", storagePath)
+	separator := "?"
+	if strings.Contains(storagePath, "?") {
+		separator = "&"
+	}
+
+	// Transactions take the write lock up front so that concurrent
+	// read-modify-write cycles queue up instead of failing with SQLITE_BUSY.
+	db, err := sql.Open("sqlite", storagePath+separator+"_txlock=immediate&_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, fmt.Errorf("database open error: %w", err)
 	}
@@ -34,37 +182,88 @@ func OpenStorage(storagePath string) (*Storage, error) {
 	if pingErr := db.Ping(); pingErr != nil {
 		return nil, fmt.Errorf("database connection error: %w", pingErr)
 	} else {
-		fmt.Println("Connected to database!")
+		slog.Info("connected to database", "path", storagePath)
 	}
 
-	_, err = db.Exec(`
-	CREATE TABLE IF NOT EXISTS scheduler (
-    	id INTEGER PRIMARY KEY AUTOINCREMENT,
-    	date CHAR(8) NOT NULL DEFAULT '',
-    	title TEXT NOT NULL DEFAULT '',
-    	comment TEXT NOT NULL DEFAULT '',
-    	repeat VARCHAR(128) NOT NULL DEFAULT '');
-	`)
+	if err := migrate(context.Background(), db); err != nil {
+		return nil, fmt.Errorf("database migration error: %w", err)
+	}
+
+	return NewStorage(db), nil
+}
+func parseID(id string) (int, error) {
+
+	parsedID, err := strconv.Atoi(id)
 	if err != nil {
-		return nil, fmt.Errorf("database create error: %w", err)
+		return 0, fmt.Errorf("parse ID %q error: %v: %w", id, err, ErrInvalidID)
+	}
+
+	return parsedID, nil
+}
+
+const taskColumns = "id, date, title, comment, repeat, created_at, updated_at"
+
+// TaskScope selects the tasks a request works on: the personal tasks of
+// OwnerID, or every task of a shared list when ListID is set. New tasks are
+// attributed to OwnerID either way.
+type TaskScope struct {
+	OwnerID int64
+	ListID  int64
+}
+
+func (s TaskScope) where() (string, []interface{}) {
+
+	if s.ListID != 0 {
+		return "list_id=?", []interface{}{s.ListID}
+	}
+
+	return "owner_id=? AND list_id IS NULL", []interface{}{s.OwnerID}
+}
+
+func (s TaskScope) listID() interface{} {
+
+	if s.ListID != 0 {
+		return s.ListID
 	}
 
-	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS scheduler_date on scheduler(date);`)
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTask(row rowScanner) (models.Task, error) {
+
+	var task models.Task
+	var createdAt, updatedAt int64
+
+	err := row.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &createdAt, &updatedAt)
 	if err != nil {
-		return nil, fmt.Errorf("index create error: %w", err)
+		return task, err
 	}
-	return NewStorage(db), nil
+
+	task.CreatedAt = time.Unix(createdAt, 0).UTC()
+	task.UpdatedAt = time.Unix(updatedAt, 0).UTC()
+
+	return task, nil
 }
-func (s *Storage) AddTask(task models.Task) (int64, error) {
 
-	statement, err := s.db.Prepare("INSERT INTO scheduler(date, title, comment, repeat) VALUES(?, ?, ?, ?)")
+func (s *Storage) AddTask(ctx context.Context, scope TaskScope, task models.Task) (_ int64, err error) {
+
+	ctx, end := s.observe(ctx, "AddTask")
+	defer func() { end(err) }()
+
+	statement, err := s.q.PrepareContext(ctx, "INSERT INTO scheduler(date, title, comment, repeat, created_at, updated_at, owner_id, list_id) VALUES(?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return 0, fmt.Errorf("statement prepration error: %w", err)
 	}
 
 	defer statement.Close()
 
-	result, err := statement.Exec(task.Date, task.Title, task.Comment, task.Repeat)
+	now := time.Now().Unix()
+
+	result, err := statement.ExecContext(ctx, task.Date, task.Title, task.Comment, task.Repeat, now, now, scope.OwnerID, scope.listID())
 	if err != nil {
 		return 0, fmt.Errorf("statement execution error: %w", err)
 	}
@@ -76,11 +275,16 @@ func (s *Storage) AddTask(task models.Task) (int64, error) {
 	return taskID, nil
 }
 
-func (s *Storage) GetTasks() ([]models.Task, error) {
+func (s *Storage) GetTasks(ctx context.Context, scope TaskScope) (_ []models.Task, err error) {
+
+	ctx, end := s.observe(ctx, "GetTasks")
+	defer func() { end(err) }()
 
 	output := make([]models.Task, 0, constants.TasksLimit)
+	condition, arguments := scope.where()
 
-	rows, err := s.db.Query("SELECT id, date, title, comment, repeat FROM scheduler ORDER BY date LIMIT ?", constants.TasksLimit)
+	rows, err := s.q.QueryContext(ctx, "SELECT "+taskColumns+" FROM scheduler WHERE "+condition+" ORDER BY date LIMIT ?",
+		append(arguments, constants.TasksLimit)...)
 	if err != nil {
 		return output, fmt.Errorf("row query error: %w", err)
 	}
@@ -89,15 +293,11 @@ func (s *Storage) GetTasks() ([]models.Task, error) {
 
 	for rows.Next() {
 
-		var getTasks models.Task
-
-		err := rows.Scan(&getTasks.ID, &getTasks.Date, &getTasks.Title, &getTasks.Comment, &getTasks.Repeat)
+		getTasks, err := scanTask(rows)
 		if err != nil {
-			return output, fmt.Errorf("row scan error: %w\n", err)
+			return output, fmt.Errorf("row scan error: %w", err)
 		}
 
-		getTasks.ID = fmt.Sprint(getTasks.ID)
-
 		output = append(output, getTasks)
 	}
 
@@ -107,20 +307,58 @@ func (s *Storage) GetTasks() ([]models.Task, error) {
 	return output, nil
 }
 
-func (s *Storage) GetTask(id string) (models.Task, error) {
+// ExportTasks returns every task of the scope, without the page limit of
+// GetTasks, in the order they were created.
+func (s *Storage) ExportTasks(ctx context.Context, scope TaskScope) (_ []models.Task, err error) {
+
+	ctx, end := s.observe(ctx, "ExportTasks")
+	defer func() { end(err) }()
+
+	output := []models.Task{}
+	condition, arguments := scope.where()
+
+	rows, err := s.q.QueryContext(ctx, "SELECT "+taskColumns+" FROM scheduler WHERE "+condition+" ORDER BY id", arguments...)
+	if err != nil {
+		return output, fmt.Errorf("row query error: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		task, err := scanTask(rows)
+		if err != nil {
+			return output, fmt.Errorf("row scan error: %w", err)
+		}
+
+		output = append(output, task)
+	}
+
+	if err := rows.Err(); err != nil {
+		return output, fmt.Errorf("row iteration error: %w", err)
+	}
+	return output, nil
+}
+
+func (s *Storage) GetTask(ctx context.Context, scope TaskScope, id string) (_ models.Task, err error) {
+
+	ctx, end := s.observe(ctx, "GetTask")
+	defer func() { end(err) }()
 
 	var getTask models.Task
 
-	parsedID, err := strconv.Atoi(id)
+	parsedID, err := parseID(id)
 	if err != nil {
-		return getTask, fmt.Errorf("parse ID error: %w", err)
+		return getTask, err
 	}
 
-	row := s.db.QueryRow("SELECT id, date, title, comment, repeat FROM scheduler WHERE id=?", parsedID)
+	condition, arguments := scope.where()
+	row := s.q.QueryRowContext(ctx, "SELECT "+taskColumns+" FROM scheduler WHERE id=? AND "+condition,
+		append([]interface{}{parsedID}, arguments...)...)
 
-	err = row.Scan(&getTask.ID, &getTask.Date, &getTask.Title, &getTask.Comment, &getTask.Repeat)
+	getTask, err = scanTask(row)
 	if errors.Is(err, sql.ErrNoRows) {
-		return getTask, fmt.Errorf("task with id %v not found", id)
+		return getTask, fmt.Errorf("task with id %v: %w", id, ErrNotFound)
 	} else if err != nil {
 		return getTask, fmt.Errorf("scan error: %w", err)
 	}
@@ -128,10 +366,19 @@ func (s *Storage) GetTask(id string) (models.Task, error) {
 	return getTask, nil
 }
 
-func (s *Storage) EditTask(task models.Task) error {
+func (s *Storage) EditTask(ctx context.Context, scope TaskScope, task models.Task) (err error) {
+
+	ctx, end := s.observe(ctx, "EditTask")
+	defer func() { end(err) }()
 
-	result, err := s.db.Exec("UPDATE scheduler SET date=?, title=?, comment=?, repeat=? WHERE id=?",
-		task.Date, task.Title, task.Comment, task.Repeat, task.ID)
+	parsedID, err := parseID(task.ID)
+	if err != nil {
+		return err
+	}
+
+	condition, arguments := scope.where()
+	result, err := s.q.ExecContext(ctx, "UPDATE scheduler SET date=?, title=?, comment=?, repeat=?, updated_at=? WHERE id=? AND "+condition,
+		append([]interface{}{task.Date, task.Title, task.Comment, task.Repeat, time.Now().Unix(), parsedID}, arguments...)...)
 	if err != nil {
 		return fmt.Errorf("execution error: %w", err)
 	}
@@ -142,20 +389,24 @@ func (s *Storage) EditTask(task models.Task) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("task with id %v not found", task.ID)
+		return fmt.Errorf("task with id %v: %w", task.ID, ErrNotFound)
 	}
 
 	return nil
 }
 
-func (s *Storage) DeleteTask(id string) error {
+func (s *Storage) DeleteTask(ctx context.Context, scope TaskScope, id string) (err error) {
 
-	parsedID, err := strconv.Atoi(id)
+	ctx, end := s.observe(ctx, "DeleteTask")
+	defer func() { end(err) }()
+
+	parsedID, err := parseID(id)
 	if err != nil {
-		return fmt.Errorf("parse ID error: %w", err)
+		return err
 	}
 
-	result, err := s.db.Exec("DELETE FROM scheduler WHERE id=?", parsedID)
+	condition, arguments := scope.where()
+	result, err := s.q.ExecContext(ctx, "DELETE FROM scheduler WHERE id=? AND "+condition, append([]interface{}{parsedID}, arguments...)...)
 	if err != nil {
 		return fmt.Errorf("execution error: %w", err)
 	}
@@ -166,29 +417,32 @@ func (s *Storage) DeleteTask(id string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("task with id %v not found", parsedID)
+		return fmt.Errorf("task with id %v: %w", parsedID, ErrNotFound)
 	}
 
 	return nil
 }
 
-func (s *Storage) SearchTasks(searchQuery string) ([]models.Task, error) {
+func (s *Storage) SearchTasks(ctx context.Context, scope TaskScope, searchQuery string) (_ []models.Task, err error) {
+
+	ctx, end := s.observe(ctx, "SearchTasks")
+	defer func() { end(err) }()
 
 	var query string
-	var arguments []interface{}
 	output := make([]models.Task, 0, constants.TasksLimit)
+	condition, arguments := scope.where()
 
 	date, err := services.IsDate(searchQuery)
 	if err == nil {
-		query = "SELECT * FROM scheduler WHERE date=? LIMIT ?"
+		query = "SELECT " + taskColumns + " FROM scheduler WHERE " + condition + " AND date=? LIMIT ?"
 		arguments = append(arguments, date, constants.TasksLimit)
 	} else {
-		query = "SELECT * FROM scheduler WHERE title LIKE ? OR comment LIKE ? ORDER BY date LIMIT ?"
+		query = "SELECT " + taskColumns + " FROM scheduler WHERE " + condition + " AND (title LIKE ? OR comment LIKE ?) ORDER BY date LIMIT ?"
 		searchPattern := "%" + searchQuery + "%"
 		arguments = append(arguments, searchPattern, searchPattern, constants.TasksLimit)
 	}
 
-	rows, err := s.db.Query(query, arguments...)
+	rows, err := s.q.QueryContext(ctx, query, arguments...)
 	if err != nil {
 		return output, fmt.Errorf("row query error: %w", err)
 	}
@@ -197,15 +451,11 @@ func (s *Storage) SearchTasks(searchQuery string) ([]models.Task, error) {
 
 	for rows.Next() {
 
-		var getTasks models.Task
-
-		err := rows.Scan(&getTasks.ID, &getTasks.Date, &getTasks.Title, &getTasks.Comment, &getTasks.Repeat)
+		getTasks, err := scanTask(rows)
 		if err != nil {
-			return output, fmt.Errorf("row scan error: %w\n", err)
+			return output, fmt.Errorf("row scan error: %w", err)
 		}
 
-		getTasks.ID = fmt.Sprint(getTasks.ID)
-
 		output = append(output, getTasks)
 	}
 
@@ -215,3 +465,37 @@ func (s *Storage) SearchTasks(searchQuery string) ([]models.Task, error) {
 	return output, nil
 
 }
+
+// CountTasksByState counts all stored tasks as overdue, today or upcoming
+// relative to today (in constants.DateFormat).
+func (s *Storage) CountTasksByState(ctx context.Context, today string) (_ map[string]int, err error) {
+
+	ctx, end := s.observe(ctx, "CountTasksByState")
+	defer func() { end(err) }()
+
+	counts := map[string]int{"overdue": 0, "today": 0, "upcoming": 0}
+
+	rows, err := s.q.QueryContext(ctx, `SELECT CASE WHEN date < ? THEN 'overdue' WHEN date = ? THEN 'today' ELSE 'upcoming' END AS state,
+		COUNT(*) FROM scheduler GROUP BY state`, today, today)
+	if err != nil {
+		return counts, fmt.Errorf("row query error: %w", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+
+		var state string
+		var count int
+		if err := rows.Scan(&state, &count); err != nil {
+			return counts, fmt.Errorf("row scan error: %w", err)
+		}
+
+		counts[state] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return counts, fmt.Errorf("row iteration error: %w", err)
+	}
+	return counts, nil
+}
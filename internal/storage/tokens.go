@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"todo_restapi/internal/models"
+)
+
+var (
+	ErrTokenNotFound   = errors.New("token not found")
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+func (s *Storage) CreateTokenFamily(ctx context.Context, session models.Session) (err error) {
+
+	ctx, end := s.observe(ctx, "CreateTokenFamily")
+	defer func() { end(err) }()
+
+	now := time.Now().Unix()
+
+	_, err = s.q.ExecContext(ctx, `INSERT INTO token_families(id, user_id, ip, user_agent, created_at, last_seen_at)
+		VALUES(?, ?, ?, ?, ?, ?)`, session.ID, session.UserID, session.IP, session.UserAgent, now, now)
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	return nil
+}
+
+// GetSessions returns the sessions of userID that can still be refreshed,
+// most recently used first.
+func (s *Storage) GetSessions(ctx context.Context, userID int64) (_ []models.Session, err error) {
+
+	ctx, end := s.observe(ctx, "GetSessions")
+	defer func() { end(err) }()
+
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT f.id, f.user_id, f.ip, f.user_agent, f.created_at, f.last_seen_at
+		FROM token_families f
+		WHERE f.user_id=? AND f.revoked_at IS NULL AND EXISTS(
+			SELECT 1 FROM refresh_tokens r WHERE r.family_id = f.id AND r.used_at IS NULL AND r.expires_at >= ?)
+		ORDER BY f.last_seen_at DESC, f.created_at DESC`, userID, time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+
+		var session models.Session
+		var createdAt, lastSeenAt int64
+
+		err := rows.Scan(&session.ID, &session.UserID, &session.IP, &session.UserAgent, &createdAt, &lastSeenAt)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+
+		session.CreatedAt = time.Unix(createdAt, 0).UTC()
+		session.LastSeenAt = time.Unix(lastSeenAt, 0).UTC()
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession revokes a session of userID, reporting ErrSessionNotFound for
+// sessions of other users and those already ended.
+func (s *Storage) RevokeSession(ctx context.Context, userID int64, familyID string) (err error) {
+
+	ctx, end := s.observe(ctx, "RevokeSession")
+	defer func() { end(err) }()
+
+	result, err := s.q.ExecContext(ctx, "UPDATE token_families SET revoked_at=? WHERE id=? AND user_id=? AND revoked_at IS NULL",
+		time.Now().Unix(), familyID, userID)
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected error: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("session %s: %w", familyID, ErrSessionNotFound)
+	}
+
+	return nil
+}
+
+// TouchSession records activity in the session, at most once a minute like
+// TouchAPIKey.
+func (s *Storage) TouchSession(ctx context.Context, familyID string, now time.Time) (err error) {
+
+	ctx, end := s.observe(ctx, "TouchSession")
+	defer func() { end(err) }()
+
+	_, err = s.q.ExecContext(ctx, "UPDATE token_families SET last_seen_at=? WHERE id=? AND last_seen_at < ?",
+		now.Unix(), familyID, now.Add(-time.Minute).Unix())
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RevokeTokenFamily(ctx context.Context, familyID string) (err error) {
+
+	ctx, end := s.observe(ctx, "RevokeTokenFamily")
+	defer func() { end(err) }()
+
+	_, err = s.q.ExecContext(ctx, "UPDATE token_families SET revoked_at=? WHERE id=? AND revoked_at IS NULL",
+		time.Now().Unix(), familyID)
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	return nil
+}
+
+// AddRefreshToken stores the hash of a refresh token and drops tokens that
+// have already expired.
+func (s *Storage) AddRefreshToken(ctx context.Context, tokenHash string, familyID string, expiresAt time.Time) (err error) {
+
+	ctx, end := s.observe(ctx, "AddRefreshToken")
+	defer func() { end(err) }()
+
+	if _, err := s.q.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE expires_at < ?", time.Now().Unix()); err != nil {
+		return fmt.Errorf("cleanup error: %w", err)
+	}
+
+	_, err = s.q.ExecContext(ctx, "INSERT INTO refresh_tokens(token_hash, family_id, expires_at) VALUES(?, ?, ?)",
+		tokenHash, familyID, expiresAt.Unix())
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetRefreshToken(ctx context.Context, tokenHash string) (_ models.RefreshToken, err error) {
+
+	ctx, end := s.observe(ctx, "GetRefreshToken")
+	defer func() { end(err) }()
+
+	var token models.RefreshToken
+	var expiresAt int64
+	var usedAt, revokedAt sql.NullInt64
+
+	err = s.q.QueryRowContext(ctx, `
+		SELECT r.token_hash, r.family_id, f.user_id, r.expires_at, r.used_at, f.revoked_at
+		FROM refresh_tokens r JOIN token_families f ON f.id = r.family_id
+		WHERE r.token_hash=?`, tokenHash).
+		Scan(&token.TokenHash, &token.FamilyID, &token.UserID, &expiresAt, &usedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return token, ErrTokenNotFound
+	} else if err != nil {
+		return token, fmt.Errorf("scan error: %w", err)
+	}
+
+	token.ExpiresAt = time.Unix(expiresAt, 0).UTC()
+	token.Used = usedAt.Valid
+	if usedAt.Valid {
+		token.UsedAt = time.Unix(usedAt.Int64, 0).UTC()
+	}
+	token.Revoked = revokedAt.Valid
+
+	return token, nil
+}
+
+func (s *Storage) MarkRefreshTokenUsed(ctx context.Context, tokenHash string, now time.Time) (err error) {
+
+	ctx, end := s.observe(ctx, "MarkRefreshTokenUsed")
+	defer func() { end(err) }()
+
+	result, err := s.q.ExecContext(ctx, "UPDATE refresh_tokens SET used_at=? WHERE token_hash=? AND used_at IS NULL",
+		now.Unix(), tokenHash)
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected error: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrTokenNotFound
+	}
+
+	return nil
+}
+
+// RevokeToken puts an access token id on the denylist until the token would
+// have expired anyway.
+func (s *Storage) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) (err error) {
+
+	ctx, end := s.observe(ctx, "RevokeToken")
+	defer func() { end(err) }()
+
+	if _, err := s.q.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at < ?", time.Now().Unix()); err != nil {
+		return fmt.Errorf("cleanup error: %w", err)
+	}
+
+	_, err = s.q.ExecContext(ctx, "INSERT OR IGNORE INTO revoked_tokens(jti, expires_at) VALUES(?, ?)", jti, expiresAt.Unix())
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	return nil
+}
+
+// IsTokenRevoked reports whether the access token itself or the sign-in
+// family it was issued for has been revoked.
+func (s *Storage) IsTokenRevoked(ctx context.Context, jti string, familyID string) (_ bool, err error) {
+
+	ctx, end := s.observe(ctx, "IsTokenRevoked")
+	defer func() { end(err) }()
+
+	var revoked bool
+
+	err = s.q.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti=?)
+			OR EXISTS(SELECT 1 FROM token_families WHERE id=? AND revoked_at IS NOT NULL)`,
+		jti, familyID).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("scan error: %w", err)
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"todo_restapi/internal/models"
+)
+
+const AdminUserID = 1
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("user already exists")
+)
+
+func (s *Storage) CreateUser(ctx context.Context, login string, passwordHash string) (_ int64, err error) {
+
+	ctx, end := s.observe(ctx, "CreateUser")
+	defer func() { end(err) }()
+
+	result, err := s.q.ExecContext(ctx, "INSERT INTO users(login, password_hash, created_at) VALUES(?, ?, ?)",
+		login, passwordHash, time.Now().Unix())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, fmt.Errorf("login %q: %w", login, ErrUserExists)
+		}
+		return 0, fmt.Errorf("execution error: %w", err)
+	}
+
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("getting ID error: %w", err)
+	}
+	return userID, nil
+}
+
+const userColumns = "id, login, password_hash, created_at, totp_secret, totp_enabled, totp_last_step"
+
+func (s *Storage) GetUser(ctx context.Context, id int64) (_ models.User, err error) {
+
+	ctx, end := s.observe(ctx, "GetUser")
+	defer func() { end(err) }()
+	return s.getUser(ctx, "SELECT "+userColumns+" FROM users WHERE id=?", id)
+}
+
+func (s *Storage) GetUserByLogin(ctx context.Context, login string) (_ models.User, err error) {
+
+	ctx, end := s.observe(ctx, "GetUserByLogin")
+	defer func() { end(err) }()
+	return s.getUser(ctx, "SELECT "+userColumns+" FROM users WHERE login=?", login)
+}
+
+func (s *Storage) getUser(ctx context.Context, query string, argument interface{}) (models.User, error) {
+
+	var user models.User
+	var createdAt int64
+
+	err := s.q.QueryRowContext(ctx, query, argument).Scan(&user.ID, &user.Login, &user.PasswordHash, &createdAt,
+		&user.TOTPSecret, &user.TOTPEnabled, &user.TOTPLastStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, fmt.Errorf("user %v: %w", argument, ErrUserNotFound)
+	} else if err != nil {
+		return user, fmt.Errorf("scan error: %w", err)
+	}
+
+	user.CreatedAt = time.Unix(createdAt, 0).UTC()
+
+	return user, nil
+}
+
+// SetTOTPSecret starts an enrollment; the secret is not enforced until
+// EnableTOTP confirms the user can produce codes for it.
+func (s *Storage) SetTOTPSecret(ctx context.Context, userID int64, secret string) (err error) {
+
+	ctx, end := s.observe(ctx, "SetTOTPSecret")
+	defer func() { end(err) }()
+
+	_, err = s.q.ExecContext(ctx, "UPDATE users SET totp_secret=?, totp_enabled=0, totp_last_step=0 WHERE id=?", secret, userID)
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) EnableTOTP(ctx context.Context, userID int64, recoveryCodeHashes []string) (err error) {
+
+	ctx, end := s.observe(ctx, "EnableTOTP")
+	defer func() { end(err) }()
+
+	if _, err := s.q.ExecContext(ctx, "UPDATE users SET totp_enabled=1 WHERE id=?", userID); err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	if _, err := s.q.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id=?", userID); err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	for _, hash := range recoveryCodeHashes {
+		if _, err := s.q.ExecContext(ctx, "INSERT INTO recovery_codes(user_id, code_hash) VALUES(?, ?)", userID, hash); err != nil {
+			return fmt.Errorf("execution error: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) DisableTOTP(ctx context.Context, userID int64) (err error) {
+
+	ctx, end := s.observe(ctx, "DisableTOTP")
+	defer func() { end(err) }()
+
+	_, err = s.q.ExecContext(ctx, "UPDATE users SET totp_secret='', totp_enabled=0, totp_last_step=0 WHERE id=?", userID)
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	if _, err := s.q.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id=?", userID); err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the time step of an accepted code. It returns false if
+// that step or a later one was already used, which means a replayed code.
+func (s *Storage) UseTOTPStep(ctx context.Context, userID int64, step int64) (_ bool, err error) {
+
+	ctx, end := s.observe(ctx, "UseTOTPStep")
+	defer func() { end(err) }()
+
+	result, err := s.q.ExecContext(ctx, "UPDATE users SET totp_last_step=? WHERE id=? AND totp_last_step < ?", step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("execution error: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("rows affected error: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (_ bool, err error) {
+
+	ctx, end := s.observe(ctx, "UseRecoveryCode")
+	defer func() { end(err) }()
+
+	result, err := s.q.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id=? AND code_hash=?", userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("execution error: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("rows affected error: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (s *Storage) CountRecoveryCodes(ctx context.Context, userID int64) (_ int, err error) {
+
+	ctx, end := s.observe(ctx, "CountRecoveryCodes")
+	defer func() { end(err) }()
+
+	var count int
+	if err := s.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM recovery_codes WHERE user_id=?", userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("scan error: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetUserIDByIdentity finds the local user linked to an external identity
+// provider account.
+func (s *Storage) GetUserIDByIdentity(ctx context.Context, issuer string, subject string) (_ int64, err error) {
+
+	ctx, end := s.observe(ctx, "GetUserIDByIdentity")
+	defer func() { end(err) }()
+
+	var userID int64
+
+	err = s.q.QueryRowContext(ctx, "SELECT user_id FROM user_identities WHERE issuer=? AND subject=?", issuer, subject).
+		Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("identity %s %s: %w", issuer, subject, ErrUserNotFound)
+	} else if err != nil {
+		return 0, fmt.Errorf("scan error: %w", err)
+	}
+
+	return userID, nil
+}
+
+func (s *Storage) LinkIdentity(ctx context.Context, issuer string, subject string, userID int64) (err error) {
+
+	ctx, end := s.observe(ctx, "LinkIdentity")
+	defer func() { end(err) }()
+
+	_, err = s.q.ExecContext(ctx, "INSERT INTO user_identities(issuer, subject, user_id, created_at) VALUES(?, ?, ?, ?)",
+		issuer, subject, userID, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("execution error: %w", err)
+	}
+
+	return nil
+}
@@ -1,58 +1,106 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/go-chi/chi/v5"
 	"todo_restapi/internal/config"
-	"todo_restapi/internal/http-server/handlers"
-	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/http-server/router"
+	"todo_restapi/internal/http-server/server"
+	"todo_restapi/internal/logger"
 	"todo_restapi/internal/storage"
+	"todo_restapi/internal/tracing"
 )
 
 func main() {
 
-	cfg := config.LoadConfig()
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
 
-	database, err := storage.OpenStorage(cfg.StoragePath)
+	runCommand(name, args)
+}
+
+// run serves until SIGINT or SIGTERM and returns only after in-flight
+// requests are finished and the database is closed.
+func run(args []string) error {
+
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configFlags := config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(configFlags)
 	if err != nil {
-		log.Fatalf("OpenStorage: %v", err)
+		return err
+	}
+
+	shared := config.NewShared(cfg)
+	slog.SetDefault(logger.New(os.Stderr, cfg.LogFormat, shared.LogLevel()))
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, os.Stdout)
+	if err != nil {
+		return fmt.Errorf("tracing.Setup: %w", err)
 	}
 
 	defer func() {
-		if err := database.CloseStorage(); err != nil {
-			log.Printf("failed to close database: %v", err)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("failed to flush spans", "error", err)
 		}
 	}()
 
-	taskHandler := handlers.NewTaskHandler(database, cfg)
-	autService := middlewares.NewAuthService(cfg)
+	database, err := storage.OpenStorage(cfg.StoragePath)
+	if err != nil {
+		return fmt.Errorf("OpenStorage: %w", err)
+	}
 
-	router := chi.NewRouter()
+	database.SetQueryTimeout(cfg.QueryTimeout)
 
-	router.Get("/", func(write http.ResponseWriter, request *http.Request) {
-		http.ServeFile(write, request, "web/index.html")
-	})
-	router.Handle("/*", http.StripPrefix("/", http.FileServer(http.Dir("web"))))
+	defer func() {
+		if err := database.CloseStorage(); err != nil {
+			slog.Error("failed to close database", "error", err)
+		}
+	}()
 
-	router.Get("/api/nextdate", taskHandler.NextDate)
+	srv := server.New(cfg, nil)
 
-	router.Post("/api/signin", taskHandler.Authentication)
-	router.With(middlewares.Auth(autService)).Route("/api", func(router chi.Router) {
+	mux, err := router.New(shared, database, srv.Draining)
+	if err != nil {
+		return fmt.Errorf("router.New: %w", err)
+	}
+	srv.HTTP.Handler = mux
 
-		router.Get("/task", taskHandler.GetTask)
-		router.Post("/task", taskHandler.AddTask)
-		router.Put("/task", taskHandler.EditTask)
-		router.Delete("/task", taskHandler.DeleteTask)
+	listener, err := net.Listen("tcp", cfg.Port)
+	if err != nil {
+		return fmt.Errorf("listen error: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-		router.Get("/tasks", taskHandler.GetTasks)
-		router.HandleFunc("/task/done", taskHandler.TaskIsDone)
-	})
+	go func() {
+		if err := shared.Watch(ctx, configFlags); err != nil {
+			slog.Error("config reload disabled", "error", err)
+		}
+	}()
 
-	fmt.Printf("Server is running on port%s...\n", cfg.Port)
-	if err := http.ListenAndServe(cfg.Port, router); err != nil {
-		log.Fatalf("server run error: %v\n", err)
+	slog.Info("server is running", "addr", listener.Addr().String())
+	if err := srv.Serve(ctx, listener); err != nil {
+		return fmt.Errorf("server run error: %w", err)
 	}
+
+	slog.Info("server stopped")
+	return nil
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// migrateDatabase implements `todo_restapi migrate` and applies the pending
+// migrations without starting the server, e.g. before a rolling deploy.
+func migrateDatabase(args []string, stdout io.Writer) error {
+
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	cfg, err := loadConfig(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return errors.New("usage: migrate [flags]")
+	}
+
+	database, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer database.CloseStorage()
+
+	current, latest, err := database.SchemaVersion(context.Background())
+	if err != nil {
+		return fmt.Errorf("SchemaVersion: %w", err)
+	}
+
+	_, err = fmt.Fprintf(stdout, "schema version %d of %d\n", current, latest)
+	return err
+}
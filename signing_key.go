@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+
+	"todo_restapi/internal/signing"
+)
+
+// generateSigningKey implements `todo_restapi signing-key [-alg EdDSA|RS256]`
+// and prints a new private key in PEM for TODO_SIGNING_KEYS.
+func generateSigningKey(args []string, stdout io.Writer) error {
+
+	flags := flag.NewFlagSet("signing-key", flag.ContinueOnError)
+	alg := flags.String("alg", "EdDSA", "signing algorithm: EdDSA or RS256")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return errors.New("usage: signing-key [-alg EdDSA|RS256]")
+	}
+
+	key, err := signing.GenerateKey(*alg)
+	if err != nil {
+		return err
+	}
+
+	_, err = stdout.Write(key)
+	return err
+}
@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type apiKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Key        string     `json:"key"`
+}
+
+func serveBearer(t *testing.T, mux *chi.Mux, method string, path string, token string, values any) *httptest.ResponseRecorder {
+	var data []byte
+	if values != nil {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+
+	request := httptest.NewRequest(method, path, bytes.NewReader(data))
+	if values != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, request)
+	return resp
+}
+
+func TestBearerToken(t *testing.T) {
+	mux := newTestRouter(t)
+	pair := decodeTokens(t, serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{"password": "12345"}))
+
+	resp := serveBearer(t, mux, http.MethodGet, "/api/v1/tasks", pair.Token, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serveBearer(t, mux, http.MethodGet, "/api/v1/tasks", "garbage", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/tasks", nil)
+	request.Header.Set("Authorization", "Basic YWRtaW46MTIzNDU=")
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, request)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+func TestAPIKeys(t *testing.T) {
+	mux := newTestRouter(t)
+	alice := signUp(t, mux, "alice", "alice-password")
+	bob := signUp(t, mux, "bob", "bob-password")
+
+	resp := serveJSON(t, mux, http.MethodPost, "/api/keys", alice, map[string]any{
+		"name":   "ci",
+		"scopes": []string{"tasks:admin"},
+	})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/keys", alice, map[string]any{
+		"name":   "ci",
+		"scopes": []string{"tasks:read"},
+	})
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	var created apiKey
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+	assert.True(t, strings.HasPrefix(created.Key, "todo_"))
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+	assert.Equal(t, []string{"tasks:read"}, created.Scopes)
+	assert.Nil(t, created.LastUsedAt)
+
+	resp = serveBearer(t, mux, http.MethodGet, "/api/v1/tasks", created.Key, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serveBearer(t, mux, http.MethodPost, "/api/v1/tasks", created.Key, map[string]any{
+		"date":  time.Now().Format("2006-01-02"),
+		"title": "Из скрипта",
+	})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Contains(t, resp.Body.String(), "insufficient_scope")
+
+	resp = serveBearer(t, mux, http.MethodGet, "/api/keys", created.Key, nil)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Contains(t, resp.Body.String(), "session_required")
+
+	resp = serveJSON(t, mux, http.MethodGet, "/api/keys", alice, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var list struct {
+		Keys []apiKey `json:"keys"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &list))
+	if assert.Len(t, list.Keys, 1) {
+		assert.Empty(t, list.Keys[0].Key, "ключ не должен показываться повторно")
+		assert.NotNil(t, list.Keys[0].LastUsedAt)
+	}
+
+	resp = serveJSON(t, mux, http.MethodGet, "/api/keys", bob, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"keys": []}`, resp.Body.String())
+
+	keyPath := fmt.Sprintf("/api/keys/%d", created.ID)
+
+	resp = serveJSON(t, mux, http.MethodDelete, keyPath, bob, nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodDelete, keyPath, alice, nil)
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serveBearer(t, mux, http.MethodGet, "/api/v1/tasks", created.Key, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+func TestAPIKeyWriteScope(t *testing.T) {
+	mux := newTestRouter(t)
+	alice := signUp(t, mux, "alice", "alice-password")
+
+	resp := serveJSON(t, mux, http.MethodPost, "/api/keys", alice, map[string]any{
+		"scopes": []string{"tasks:read", "tasks:write"},
+	})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	var created apiKey
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+
+	resp = serveBearer(t, mux, http.MethodPost, "/api/v1/tasks", created.Key, map[string]any{
+		"date":  time.Now().Format("2006-01-02"),
+		"title": "Из скрипта",
+	})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", alice, nil)
+	assert.Contains(t, resp.Body.String(), "Из скрипта")
+
+	resp = serveBearer(t, mux, http.MethodPost, "/api/signout", created.Key, nil)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+}
+
+func TestAPIKeyListAdministration(t *testing.T) {
+	mux := newTestRouter(t)
+	alice := signUp(t, mux, "alice", "alice-password")
+	signUp(t, mux, "bob", "bob-password")
+	listPath := createList(t, mux, alice, "Общий")
+
+	resp := serveJSON(t, mux, http.MethodPost, "/api/keys", alice, map[string]any{
+		"scopes": []string{"tasks:read", "tasks:write"},
+	})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	var created apiKey
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+
+	// Задачи списка ключу доступны, а управление списком нет.
+	resp = serveBearer(t, mux, http.MethodPost, listPath+"/tasks", created.Key, map[string]any{"title": "Из скрипта"})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	resp = serveBearer(t, mux, http.MethodGet, listPath+"/members", created.Key, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	for _, request := range []struct {
+		method string
+		path   string
+		body   any
+	}{
+		{http.MethodPost, "/api/v1/lists", map[string]any{"title": "Новый"}},
+		{http.MethodPost, listPath + "/members", map[string]any{"login": "bob", "role": "owner"}},
+		{http.MethodPut, listPath + "/members/1", map[string]any{"role": "viewer"}},
+		{http.MethodDelete, listPath + "/members/1", nil},
+		{http.MethodDelete, listPath, nil},
+	} {
+		resp = serveBearer(t, mux, request.method, request.path, created.Key, request.body)
+		assert.Equal(t, http.StatusForbidden, resp.Code, request.method+" "+request.path)
+		assert.Contains(t, resp.Body.String(), "session_required")
+	}
+
+	resp = serveJSON(t, mux, http.MethodGet, listPath+"/members", alice, nil)
+	assert.NotContains(t, resp.Body.String(), "bob")
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/apperrors"
+	"todo_restapi/internal/http-server/middlewares"
+)
+
+func TestLoginLimiterBackoff(t *testing.T) {
+	now := time.Date(2024, 1, 26, 12, 0, 0, 0, time.UTC)
+	limiter := middlewares.NewLoginLimiter()
+	limiter.Now = func() time.Time { return now }
+	client := middlewares.ClientInfo{IP: "203.0.113.7"}
+
+	for i := 0; i < limiter.AccountAttempts-1; i++ {
+		assert.NoError(t, limiter.Check(client, "alice"))
+		limiter.Failure(context.Background(), client, "alice")
+	}
+	assert.NoError(t, limiter.Check(client, "alice"))
+
+	retryAfter := func() time.Duration {
+		err := limiter.Check(client, "alice")
+		appErr := apperrors.From(err)
+		if assert.Error(t, err) {
+			assert.Equal(t, http.StatusTooManyRequests, appErr.Kind.Status())
+		}
+		return appErr.RetryAfter
+	}
+
+	limiter.Failure(context.Background(), client, "alice")
+	assert.Equal(t, 30*time.Second, retryAfter())
+
+	now = now.Add(31 * time.Second)
+	assert.NoError(t, limiter.Check(client, "alice"))
+
+	limiter.Failure(context.Background(), client, "alice")
+	assert.Equal(t, time.Minute, retryAfter())
+
+	for i := 0; i < 10; i++ {
+		now = now.Add(limiter.MaxLockout)
+		limiter.Failure(context.Background(), client, "alice")
+	}
+	assert.Equal(t, limiter.MaxLockout, retryAfter(), "блокировка не должна превышать максимум")
+
+	assert.NoError(t, limiter.Check(middlewares.ClientInfo{IP: "198.51.100.1"}, "bob"))
+
+	now = now.Add(limiter.MaxLockout + time.Second)
+	limiter.Success("alice")
+	assert.NoError(t, limiter.Check(middlewares.ClientInfo{IP: "198.51.100.1"}, "alice"))
+}
+
+func TestLoginLimiterPerIP(t *testing.T) {
+	now := time.Date(2024, 1, 26, 12, 0, 0, 0, time.UTC)
+	limiter := middlewares.NewLoginLimiter()
+	limiter.Now = func() time.Time { return now }
+	client := middlewares.ClientInfo{IP: "203.0.113.7"}
+
+	for i := 0; i < limiter.IPAttempts; i++ {
+		login := fmt.Sprintf("user%d", i)
+		assert.NoError(t, limiter.Check(client, login))
+		limiter.Failure(context.Background(), client, login)
+	}
+
+	assert.Error(t, limiter.Check(client, "someone_else"))
+	assert.NoError(t, limiter.Check(middlewares.ClientInfo{IP: "198.51.100.1"}, "someone_else"))
+
+	now = now.Add(limiter.Window + time.Hour)
+	assert.NoError(t, limiter.Check(client, "someone_else"))
+}
+
+func TestLoginLimiterBounded(t *testing.T) {
+	now := time.Date(2024, 1, 26, 12, 0, 0, 0, time.UTC)
+	limiter := middlewares.NewLoginLimiter()
+	limiter.Now = func() time.Time { return now }
+	limiter.MaxTracked = 50
+
+	attacker := middlewares.ClientInfo{IP: "203.0.113.7"}
+	for i := 0; i < limiter.AccountAttempts; i++ {
+		limiter.Failure(context.Background(), attacker, "alice")
+	}
+	assert.Error(t, limiter.Check(attacker, "alice"))
+
+	for i := 0; i < 10*limiter.MaxTracked; i++ {
+		now = now.Add(time.Millisecond)
+		client := middlewares.ClientInfo{IP: fmt.Sprintf("198.51.%d.%d", i/256, i%256)}
+		limiter.Failure(context.Background(), client, fmt.Sprintf("random%d", i))
+		assert.LessOrEqual(t, limiter.Tracked(), limiter.MaxTracked)
+	}
+
+	assert.Error(t, limiter.Check(middlewares.ClientInfo{IP: "192.0.2.1"}, "alice"),
+		"заблокированные ключи не вытесняются")
+}
+
+func TestLoginLimiterFullOfLocks(t *testing.T) {
+	now := time.Date(2024, 1, 26, 12, 0, 0, 0, time.UTC)
+	limiter := middlewares.NewLoginLimiter()
+	limiter.Now = func() time.Time { return now }
+	limiter.MaxTracked = 10
+	limiter.AccountAttempts = 1
+	limiter.IPAttempts = 1
+
+	// Таблица целиком занята заблокированными ключами.
+	fillers := make([]middlewares.ClientInfo, limiter.MaxTracked/2)
+	for i := range fillers {
+		now = now.Add(time.Millisecond)
+		fillers[i] = middlewares.ClientInfo{IP: fmt.Sprintf("198.51.100.%d", i)}
+		limiter.Failure(context.Background(), fillers[i], fmt.Sprintf("filler%d", i))
+	}
+	assert.Equal(t, limiter.MaxTracked, limiter.Tracked())
+
+	attacker := middlewares.ClientInfo{IP: "203.0.113.7"}
+	limiter.Failure(context.Background(), attacker, "bob")
+	assert.Error(t, limiter.Check(attacker, "bob"), "новая ошибка всё равно учитывается")
+	assert.Error(t, limiter.Check(middlewares.ClientInfo{IP: "192.0.2.1"}, "bob"))
+	assert.LessOrEqual(t, limiter.Tracked(), limiter.MaxTracked)
+
+	// Вытесняется блокировка, которая закончилась бы раньше всех.
+	assert.NoError(t, limiter.Check(fillers[0], "filler0"))
+	assert.Error(t, limiter.Check(fillers[len(fillers)-1], fmt.Sprintf("filler%d", len(fillers)-1)))
+}
+
+func TestSignInLockout(t *testing.T) {
+	mux := newTestRouter(t)
+	failedBefore := authStat("failed_signins")
+
+	for i := 0; i < 5; i++ {
+		resp := serveJSON(t, mux, http.MethodPost, "/api/signin", "", map[string]any{"password": "wrong"})
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	}
+
+	resp := serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{"password": "12345"})
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code, "блокировка действует и для верного пароля")
+	assert.Contains(t, resp.Body.String(), "too_many_attempts")
+
+	seconds, err := strconv.Atoi(resp.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.Equal(t, 30, seconds)
+
+	assert.Equal(t, failedBefore+5, authStat("failed_signins"))
+}
+
+func authStat(name string) int64 {
+	var value int64
+	if v := middlewares.AuthStats.Get(name); v != nil {
+		_ = json.Unmarshal([]byte(v.String()), &value)
+	}
+	return value
+}
+
+func TestAuthStatsEndpoint(t *testing.T) {
+	mux := newTestRouter(t)
+	admin := decodeTokens(t, serveJSON(t, mux, http.MethodPost, "/api/signin", "", map[string]any{"password": "12345"}))
+	alice := signUp(t, mux, "alice", "alice-password")
+
+	resp := serveJSON(t, mux, http.MethodGet, "/debug/vars", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodGet, "/debug/vars", alice, nil)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodGet, "/debug/vars", admin.Token, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var vars map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &vars))
+	assert.Contains(t, vars, "auth")
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/keys", admin.Token, map[string]any{"scopes": []string{"tasks:read"}})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	var key apiKey
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &key))
+
+	resp = serveBearer(t, mux, http.MethodGet, "/debug/vars", key.Key, nil)
+	assert.Equal(t, http.StatusForbidden, resp.Code, "ключ администратора не даёт доступа к счётчикам")
+}
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/config"
+	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/http-server/router"
+	"todo_restapi/internal/models"
+	"todo_restapi/internal/storage"
+)
+
+// buildCommand builds the binary once per test and returns a function that
+// runs it with the given arguments against dbFile.
+func buildCommand(t *testing.T, dbFile string) func(args ...string) (string, error) {
+	binary := filepath.Join(t.TempDir(), "todo_restapi")
+	build := exec.Command("go", "build", "-o", binary, "..")
+	output, err := build.CombinedOutput()
+	if !assert.NoError(t, err, string(output)) {
+		t.FailNow()
+	}
+
+	return func(args ...string) (string, error) {
+		command := exec.Command(binary, args...)
+		command.Dir = t.TempDir()
+		command.Env = append(os.Environ(), "TODO_CONFIG=", "TODO_MODE=", "TODO_DBFILE="+dbFile,
+			"TODO_PASSWORD=cli-password", "TODO_SECRET=cli-secret", "TODO_SIGNING_KEYS=")
+		stdout, err := command.Output()
+		return string(stdout), err
+	}
+}
+
+func TestStorageBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbFile := filepath.Join(dir, "scheduler.db")
+	scope := storage.TaskScope{OwnerID: storage.AdminUserID}
+	ctx := context.Background()
+
+	database, err := storage.OpenStorage(dbFile)
+	assert.NoError(t, err)
+	_, err = database.AddTask(ctx, scope, models.Task{Date: "20260101", Title: "kept"})
+	assert.NoError(t, err)
+
+	backup := filepath.Join(dir, "backup.db")
+	assert.NoError(t, database.Backup(ctx, backup))
+	assert.Error(t, database.Backup(ctx, backup), "существующий файл не перезаписывается")
+
+	_, err = database.AddTask(ctx, scope, models.Task{Date: "20260102", Title: "lost"})
+	assert.NoError(t, err)
+	assert.NoError(t, database.CloseStorage())
+
+	junk := filepath.Join(dir, "junk.db")
+	assert.NoError(t, os.WriteFile(junk, []byte("not a database"), 0o600))
+	assert.Error(t, storage.Restore(junk, dbFile))
+	assert.NoError(t, storage.Restore(backup, dbFile))
+
+	database, err = storage.OpenStorage(dbFile)
+	assert.NoError(t, err)
+	defer database.CloseStorage()
+
+	tasks, err := database.ExportTasks(ctx, scope)
+	assert.NoError(t, err)
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, "kept", tasks[0].Title)
+	}
+}
+
+func TestExportTasksUnlimited(t *testing.T) {
+	database := openTestStorage(t)
+	scope := storage.TaskScope{OwnerID: storage.AdminUserID}
+
+	for i := 0; i < 15; i++ {
+		_, err := database.AddTask(context.Background(), scope, models.Task{Date: "20260101", Title: "task"})
+		assert.NoError(t, err)
+	}
+
+	tasks, err := database.ExportTasks(context.Background(), scope)
+	assert.NoError(t, err)
+	assert.Len(t, tasks, 15, "в отличие от GetTasks, без ограничения")
+}
+
+func TestCommands(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the binary")
+	}
+
+	dbFile := filepath.Join(t.TempDir(), "scheduler.db")
+	run := buildCommand(t, dbFile)
+
+	output, err := run("migrate")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^schema version (\d+) of (\d+)\n$`, output)
+
+	input := filepath.Join(t.TempDir(), "tasks.json")
+	assert.NoError(t, os.WriteFile(input, []byte(`{"tasks": [
+		{"date": "20200101", "title": "past", "repeat": "d 7"},
+		{"date": "20300101", "title": "future", "comment": "note"}
+	]}`), 0o600))
+	output, err = run("import", input)
+	assert.NoError(t, err)
+	assert.Equal(t, "imported 2 tasks\n", output)
+
+	invalid := filepath.Join(t.TempDir(), "invalid.json")
+	assert.NoError(t, os.WriteFile(invalid, []byte(`{"tasks": [{"date": "20300101", "title": "ok"}, {"date": "tomorrow", "title": "bad"}]}`), 0o600))
+	_, err = run("import", invalid)
+	assert.Error(t, err, "неверная задача отменяет весь импорт")
+
+	output, err = run("export")
+	assert.NoError(t, err)
+	var exported struct {
+		Tasks []models.Task `json:"tasks"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(output), &exported))
+	if assert.Len(t, exported.Tasks, 2) {
+		assert.Equal(t, "20200101", exported.Tasks[0].Date, "даты в прошлом не сдвигаются")
+		assert.Equal(t, "note", exported.Tasks[1].Comment)
+	}
+
+	output, err = run("token", "-ttl", "1h")
+	assert.NoError(t, err)
+	token := strings.TrimSpace(output)
+
+	cfg := testConfig()
+	cfg.Password = "cli-password"
+	cfg.SecretKey = "cli-secret"
+	database, err := storage.OpenStorage(dbFile)
+	assert.NoError(t, err)
+	t.Cleanup(func() { database.CloseStorage() })
+	shared := config.NewShared(cfg)
+	mux, err := router.NewWithAuth(shared, database, middlewares.NewAuthService(shared, database))
+	assert.NoError(t, err)
+
+	resp := serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", token, nil)
+	assert.Equal(t, http.StatusOK, resp.Code, "токен принимается сервером с той же конфигурацией")
+
+	_, err = run("token", "-user", "nobody")
+	assert.Error(t, err)
+
+	_, err = run("unknown")
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func concurrentDone(t *testing.T, id string, workers int) int {
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		successes int
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+			assert.NoError(t, err)
+			if len(ret) == 0 {
+				mu.Lock()
+				successes++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return successes
+}
+
+func TestConcurrentDone(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	const workers = 10
+	now := time.Now()
+
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Параллельное выполнение",
+		repeat: "d 2",
+	})
+
+	assert.Equal(t, workers, concurrentDone(t, id, workers))
+
+	var repeating Task
+	err := db.Get(&repeating, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 2*workers).Format(`20060102`), repeating.Date,
+		"каждый вызов /api/task/done должен сдвигать задачу ровно один раз")
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	assert.NoError(t, err)
+
+	id = addTask(t, task{
+		date:  now.Format(`20060102`),
+		title: "Разовая задача",
+	})
+
+	assert.Equal(t, 1, concurrentDone(t, id, workers),
+		"разовая задача должна быть завершена ровно один раз")
+	notFoundTask(t, id)
+}
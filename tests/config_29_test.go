@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/config"
+)
+
+// clearConfigEnv hides the TODO_* variables of the environment the tests run
+// in; empty values count as unset.
+func clearConfigEnv(t *testing.T) {
+	for _, entry := range os.Environ() {
+		if name, _, _ := strings.Cut(entry, "="); strings.HasPrefix(name, "TODO_") {
+			t.Setenv(name, "")
+		}
+	}
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func parseConfigFlags(t *testing.T, args ...string) *config.Flags {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := config.RegisterFlags(set)
+	assert.NoError(t, set.Parse(args))
+	return flags
+}
+
+func TestConfigDefaults(t *testing.T) {
+	clearConfigEnv(t)
+
+	cfg, err := config.LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, config.ModeDevelopment, cfg.Mode)
+	assert.Equal(t, ":7540", cfg.Port)
+	assert.Equal(t, config.DefaultPassword, cfg.Password)
+	assert.Equal(t, 15*time.Minute, cfg.AccessTokenTTL)
+	assert.True(t, cfg.AllowRegistration)
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	clearConfigEnv(t)
+
+	path := writeConfigFile(t, "todo.yaml", `
+port: 7001
+dbfile: /data/file.db
+access_token_ttl: 5m
+log_level: debug
+signing_keys: [a.pem, b.pem]
+`)
+	t.Setenv("TODO_PORT", "7002")
+	t.Setenv("TODO_ACCESS_TOKEN_TTL", "10m")
+
+	cfg, err := config.Load(parseConfigFlags(t, "-config", path, "-access-token-ttl", "20m"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/data/file.db", cfg.StoragePath, "file over default")
+	assert.Equal(t, ":7002", cfg.Port, "env over file")
+	assert.Equal(t, 20*time.Minute, cfg.AccessTokenTTL, "flag over env")
+	assert.Equal(t, slog.LevelDebug, cfg.LogLevel)
+	assert.Equal(t, []string{"a.pem", "b.pem"}, cfg.SigningKeys)
+}
+
+func TestConfigFileFromEnv(t *testing.T) {
+	clearConfigEnv(t)
+
+	path := writeConfigFile(t, "todo.toml", `
+mode = "production"
+password = "s3cret"
+secret = "another-secret"
+allow_registration = false
+shutdown_delay = "0s"
+`)
+	t.Setenv("TODO_CONFIG", path)
+
+	cfg, err := config.LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, config.ModeProduction, cfg.Mode)
+	assert.Equal(t, "s3cret", cfg.Password)
+	assert.False(t, cfg.AllowRegistration)
+
+	other := writeConfigFile(t, "other.yaml", "password: from-flag-file\n")
+	cfg, err = config.Load(parseConfigFlags(t, "-config", other))
+	assert.NoError(t, err)
+	assert.Equal(t, "from-flag-file", cfg.Password, "-config wins over TODO_CONFIG")
+}
+
+func TestConfigInvalidValues(t *testing.T) {
+	clearConfigEnv(t)
+
+	path := writeConfigFile(t, "todo.yaml", "read_timeout: soon\nunknown_key: 1\n")
+	t.Setenv("TODO_LOG_FORMAT", "xml")
+
+	_, err := config.Load(parseConfigFlags(t, "-config", path, "-port", "99999"))
+	assert.Error(t, err)
+	for _, part := range []string{"read_timeout", "unknown_key", "log_format", "port"} {
+		assert.Contains(t, err.Error(), part)
+	}
+
+	_, err = config.Load(parseConfigFlags(t, "-config", writeConfigFile(t, "todo.json", "{}")))
+	assert.ErrorContains(t, err, "unsupported format")
+
+	_, err = config.Load(parseConfigFlags(t, "-config", filepath.Join(t.TempDir(), "missing.yaml")))
+	assert.Error(t, err)
+
+	t.Setenv("TODO_LOG_FORMAT", "")
+	t.Setenv("TODO_OIDC_ISSUER", "https://issuer.example.com")
+	_, err = config.LoadConfig()
+	assert.ErrorContains(t, err, "TODO_OIDC_CLIENT_ID")
+}
+
+func TestConfigProductionRefusesDefaults(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("TODO_MODE", "production")
+
+	_, err := config.LoadConfig()
+	assert.ErrorContains(t, err, "TODO_PASSWORD")
+	assert.ErrorContains(t, err, "TODO_SECRET")
+
+	t.Setenv("TODO_PASSWORD", "s3cret")
+	_, err = config.LoadConfig()
+	assert.NotContains(t, err.Error(), "TODO_PASSWORD")
+	assert.ErrorContains(t, err, "TODO_SECRET")
+
+	// С ключами подписи и без OIDC секрет не используется.
+	t.Setenv("TODO_SIGNING_KEYS", "a.pem")
+	_, err = config.LoadConfig()
+	assert.NoError(t, err)
+
+	// Но им шифруется cookie входа через OIDC.
+	t.Setenv("TODO_OIDC_ISSUER", "https://issuer.example.com")
+	t.Setenv("TODO_OIDC_CLIENT_ID", "client")
+	t.Setenv("TODO_OIDC_REDIRECT_URL", "https://todo.example.com/api/v1/oidc/callback")
+	_, err = config.LoadConfig()
+	assert.ErrorContains(t, err, "TODO_SECRET")
+	t.Setenv("TODO_OIDC_ISSUER", "")
+	t.Setenv("TODO_SIGNING_KEYS", "")
+
+	t.Setenv("TODO_SECRET", "another-secret")
+	cfg, err := config.LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, config.ModeProduction, cfg.Mode)
+
+	t.Setenv("TODO_MODE", "staging")
+	_, err = config.LoadConfig()
+	assert.ErrorContains(t, err, "mode")
+}
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/models"
+	"todo_restapi/internal/storage"
+)
+
+func openTestStorage(t *testing.T) *storage.Storage {
+	database, err := storage.OpenStorage(filepath.Join(t.TempDir(), "scheduler.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { database.CloseStorage() })
+	return database
+}
+
+func TestStorageCancelledContext(t *testing.T) {
+	database := openTestStorage(t)
+	scope := storage.TaskScope{OwnerID: storage.AdminUserID}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := database.SearchTasks(ctx, scope, "anything")
+	assert.True(t, errors.Is(err, context.Canceled), err)
+
+	_, err = database.AddTask(ctx, scope, models.Task{Date: "20260101", Title: "never stored"})
+	assert.True(t, errors.Is(err, context.Canceled), err)
+
+	tasks, err := database.GetTasks(context.Background(), scope)
+	assert.NoError(t, err)
+	assert.Empty(t, tasks)
+}
+
+func TestStorageTransactionCancelled(t *testing.T) {
+	database := openTestStorage(t)
+	scope := storage.TaskScope{OwnerID: storage.AdminUserID}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := database.WithTx(ctx, func(tx *storage.Storage) error {
+		if _, err := tx.AddTask(ctx, scope, models.Task{Date: "20260101", Title: "rolled back"}); err != nil {
+			return err
+		}
+		cancel()
+		return nil
+	})
+	assert.Error(t, err)
+
+	tasks, err := database.GetTasks(context.Background(), scope)
+	assert.NoError(t, err)
+	assert.Empty(t, tasks)
+}
+
+func TestStorageQueryTimeout(t *testing.T) {
+	database := openTestStorage(t)
+	scope := storage.TaskScope{OwnerID: storage.AdminUserID}
+
+	database.SetQueryTimeout(time.Nanosecond)
+	_, err := database.GetTasks(context.Background(), scope)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+
+	database.SetQueryTimeout(0)
+	_, err = database.GetTasks(context.Background(), scope)
+	assert.NoError(t, err)
+}
+
+func TestQueryTimeoutResponse(t *testing.T) {
+	mux, authService := newTestRouterWithAuth(t, testConfig())
+	pair := decodeTokens(t, serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{"password": "12345"}))
+
+	authService.Storage.SetQueryTimeout(time.Nanosecond)
+	defer authService.Storage.SetQueryTimeout(storage.DefaultQueryTimeout)
+
+	resp := serveBearer(t, mux, http.MethodGet, "/api/v1/tasks", pair.Token, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+
+	var problem map[string]any
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &problem))
+	assert.Equal(t, "query_timeout", problem["code"])
+}
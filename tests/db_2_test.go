@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"database/sql"
 	"os"
 	"testing"
 	"time"
@@ -16,6 +17,11 @@ type Task struct {
 	Title   string `db:"title"`
 	Comment string `db:"comment"`
 	Repeat  string `db:"repeat"`
+
+	CreatedAt int64         `db:"created_at"`
+	UpdatedAt int64         `db:"updated_at"`
+	OwnerID   int64         `db:"owner_id"`
+	ListID    sql.NullInt64 `db:"list_id"`
 }
 
 func count(db *sqlx.DB) (int, error) {
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/config"
+	"todo_restapi/internal/http-server/handlers"
+	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/http-server/router"
+	"todo_restapi/internal/http-server/server"
+	"todo_restapi/internal/storage"
+)
+
+func decodeReadiness(t *testing.T, resp *httptest.ResponseRecorder) handlers.ReadinessResponse {
+	var readiness handlers.ReadinessResponse
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &readiness))
+	return readiness
+}
+
+func TestHealthz(t *testing.T) {
+	mux := newTestRouter(t)
+
+	resp := serveJSON(t, mux, http.MethodGet, "/healthz", "", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, resp.Body.String())
+}
+
+func TestReadyz(t *testing.T) {
+	mux := newTestRouter(t)
+
+	resp := serveJSON(t, mux, http.MethodGet, "/readyz", "", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "no-store", resp.Header().Get("Cache-Control"))
+
+	readiness := decodeReadiness(t, resp)
+	assert.Equal(t, "ok", readiness.Status)
+	for _, name := range []string{"database", "migrations", "disk", "shutdown"} {
+		assert.Equal(t, "ok", readiness.Checks[name].Status, name)
+	}
+}
+
+func TestReadyzFailures(t *testing.T) {
+	database, err := storage.OpenStorage(filepath.Join(t.TempDir(), "scheduler.db"))
+	assert.NoError(t, err)
+
+	draining := false
+	health := handlers.NewHealthHandler(database, func() bool { return draining })
+
+	serve := func() *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		middlewares.RequestID(http.HandlerFunc(health.Readiness)).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return resp
+	}
+
+	draining = true
+	resp := serve()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	readiness := decodeReadiness(t, resp)
+	assert.Equal(t, "fail", readiness.Status)
+	assert.Equal(t, "fail", readiness.Checks["shutdown"].Status)
+	assert.Equal(t, "ok", readiness.Checks["database"].Status)
+
+	draining = false
+	assert.NoError(t, database.CloseStorage())
+	logs := captureLogs(t)
+	resp = serve()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	readiness = decodeReadiness(t, resp)
+	assert.Equal(t, "fail", readiness.Checks["database"].Status)
+	assert.NotContains(t, resp.Body.String(), "closed", "причина сбоя не раскрывается")
+
+	// Причина попадает в журнал.
+	var logged bool
+	for _, line := range logLines(t, logs) {
+		if line["msg"] == "readiness check failed" && line["check"] == "database" {
+			logged = true
+			assert.Contains(t, line["error"], "closed")
+			assert.Equal(t, resp.Header().Get(middlewares.RequestIDHeader), line["request_id"])
+		}
+	}
+	assert.True(t, logged)
+}
+
+func TestReadyzDuringShutdown(t *testing.T) {
+	database, err := storage.OpenStorage(filepath.Join(t.TempDir(), "scheduler.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { database.CloseStorage() })
+
+	cfg := testConfig()
+	cfg.ShutdownDelay = 500 * time.Millisecond
+	cfg.ShutdownTimeout = 5 * time.Second
+
+	srv := server.New(cfg, nil)
+	mux, err := router.New(config.NewShared(cfg), database, srv.Draining)
+	assert.NoError(t, err)
+	srv.HTTP.Handler = mux
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := "http://" + listener.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, listener) }()
+
+	readyz := func() int {
+		resp, err := http.Get(address + "/readyz")
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, readyz())
+
+	cancel()
+	assert.Eventually(t, srv.Draining, time.Second, 10*time.Millisecond)
+
+	// Still serving during ShutdownDelay, but no longer ready.
+	assert.Equal(t, http.StatusServiceUnavailable, readyz())
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+	}
+}
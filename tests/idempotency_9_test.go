@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/http-server/middlewares"
+)
+
+func idempotentRequest(t *testing.T, apipath string, values map[string]any, key string) (int, map[string]any) {
+	var data []byte
+	if len(values) > 0 {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, getURL(apipath), bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return resp.StatusCode, m
+}
+
+func TestIdempotentAddTask(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	key := fmt.Sprintf("add-%d", time.Now().UnixNano())
+	values := map[string]any{
+		"date":  time.Now().Format(`20060102`),
+		"title": "Повторная отправка",
+	}
+
+	before, err := count(db)
+	assert.NoError(t, err)
+
+	code, first := idempotentRequest(t, "api/task", values, key)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.NotNil(t, first["id"])
+
+	code, second := idempotentRequest(t, "api/task", values, key)
+	assert.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, first["id"], second["id"])
+
+	after, err := count(db)
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, after)
+
+	values["title"] = "Другой запрос"
+	code, m := idempotentRequest(t, "api/task", values, key)
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	_, ok := m["error"]
+	assert.True(t, ok)
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, first["id"])
+	assert.NoError(t, err)
+}
+
+func TestIdempotentDone(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Повторное выполнение",
+		repeat: "d 5",
+	})
+
+	key := fmt.Sprintf("done-%d", time.Now().UnixNano())
+	for i := 0; i < 3; i++ {
+		code, ret := idempotentRequest(t, "api/task/done?id="+id, nil, key)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Empty(t, ret)
+	}
+
+	var repeating Task
+	err := db.Get(&repeating, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 5).Format(`20060102`), repeating.Date)
+
+	_, err = db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	assert.NoError(t, err)
+}
+
+// cancelKey carries the cancel function of a test request, so that the
+// handler can play a client that disconnects mid-request.
+type cancelKey struct{}
+
+func TestIdempotencyClientGone(t *testing.T) {
+	database := openTestStorage(t)
+
+	calls := 0
+	var handler http.HandlerFunc = func(write http.ResponseWriter, request *http.Request) {
+		calls++
+		cancel := request.Context().Value(cancelKey{}).(context.CancelFunc)
+		cancel()
+		if calls == 1 {
+			// Клиент ушёл, запрос к базе не успел.
+			write.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		write.WriteHeader(http.StatusCreated)
+		write.Write([]byte(`{"id":"1"}`))
+	}
+	mux := middlewares.Idempotency(database, time.Hour)(handler)
+
+	serve := func() *httptest.ResponseRecorder {
+		ctx, cancel := context.WithCancel(context.Background())
+		ctx = context.WithValue(ctx, cancelKey{}, cancel)
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", nil).WithContext(ctx)
+		request.Header.Set("Idempotency-Key", "client-gone")
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, request)
+		return resp
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve().Code)
+
+	resp := serve()
+	assert.Equal(t, http.StatusCreated, resp.Code, "ключ освобождён, повтор выполняется")
+	assert.Equal(t, 2, calls)
+
+	resp = serve()
+	assert.Equal(t, http.StatusCreated, resp.Code, "ответ сохранён, хотя запрос отменён")
+	assert.Equal(t, "true", resp.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type listMember struct {
+	UserID int64  `json:"user_id"`
+	Login  string `json:"login"`
+	Role   string `json:"role"`
+}
+
+func createList(t *testing.T, mux *chi.Mux, token string, title string) string {
+	resp := serveJSON(t, mux, http.MethodPost, "/api/v1/lists", token, map[string]any{"title": title})
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	var list struct {
+		ID   int64  `json:"id"`
+		Role string `json:"role"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &list))
+	assert.Equal(t, "owner", list.Role)
+	return fmt.Sprintf("/api/v1/lists/%d", list.ID)
+}
+
+func invite(t *testing.T, mux *chi.Mux, token string, listPath string, login string, role string) listMember {
+	resp := serveJSON(t, mux, http.MethodPost, listPath+"/members", token, map[string]any{"login": login, "role": role})
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	var member listMember
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &member))
+	assert.Equal(t, login, member.Login)
+	assert.Equal(t, role, member.Role)
+	return member
+}
+
+func TestListRoles(t *testing.T) {
+	mux := newTestRouter(t)
+	owner := signUp(t, mux, "list_owner", "owner-password")
+	viewer := signUp(t, mux, "list_viewer", "viewer-password")
+	editor := signUp(t, mux, "list_editor", "editor-password")
+
+	listPath := createList(t, mux, owner, "Дом")
+	task := map[string]any{"title": "Купить хлеб"}
+
+	resp := serveJSON(t, mux, http.MethodGet, listPath+"/tasks", viewer, nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code, "чужой список не виден")
+	assert.Contains(t, resp.Body.String(), "list_not_found")
+
+	viewerMember := invite(t, mux, owner, listPath, "list_viewer", "viewer")
+	editorMember := invite(t, mux, owner, listPath, "list_editor", "editor")
+
+	resp = serveJSON(t, mux, http.MethodPost, listPath+"/tasks", viewer, task)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Contains(t, resp.Body.String(), "insufficient_role")
+
+	resp = serveJSON(t, mux, http.MethodPost, listPath+"/tasks", editor, task)
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var created v1Task
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+	taskPath := fmt.Sprintf("%s/tasks/%d", listPath, created.ID)
+	assert.Equal(t, taskPath, resp.Header().Get("Location"))
+
+	for _, token := range []string{owner, viewer, editor} {
+		resp = serveJSON(t, mux, http.MethodGet, taskPath, token, nil)
+		assert.Equal(t, http.StatusOK, resp.Code, "задачу списка видят все участники")
+	}
+
+	resp = serveJSON(t, mux, http.MethodGet, fmt.Sprintf("/api/v1/tasks/%d", created.ID), editor, nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code, "задачи списка не попадают в личные")
+
+	resp = serveJSON(t, mux, http.MethodDelete, taskPath, viewer, nil)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodPost, listPath+"/members", editor, map[string]any{"login": "list_viewer"})
+	assert.Equal(t, http.StatusForbidden, resp.Code, "приглашать может только владелец")
+
+	resp = serveJSON(t, mux, http.MethodPut, fmt.Sprintf("%s/members/%d", listPath, viewerMember.UserID), editor,
+		map[string]any{"role": "owner"})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodPut, fmt.Sprintf("%s/members/%d", listPath, viewerMember.UserID), owner,
+		map[string]any{"role": "editor"})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	resp = serveJSON(t, mux, http.MethodPost, taskPath+"/done", viewer, nil)
+	assert.Equal(t, http.StatusNoContent, resp.Code, "после повышения роли можно менять задачи")
+
+	resp = serveJSON(t, mux, http.MethodDelete, listPath, editor, nil)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodDelete, fmt.Sprintf("%s/members/%d", listPath, viewerMember.UserID), editor, nil)
+	assert.Equal(t, http.StatusForbidden, resp.Code, "удалить другого участника может только владелец")
+
+	resp = serveJSON(t, mux, http.MethodDelete, fmt.Sprintf("%s/members/%d", listPath, editorMember.UserID), editor, nil)
+	assert.Equal(t, http.StatusNoContent, resp.Code, "участник может выйти из списка сам")
+
+	resp = serveJSON(t, mux, http.MethodGet, listPath, editor, nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodGet, listPath+"/members", owner, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var members struct {
+		Members []listMember `json:"members"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &members))
+	assert.Len(t, members.Members, 2)
+
+	resp = serveJSON(t, mux, http.MethodDelete, listPath, owner, nil)
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodGet, listPath+"/tasks", viewer, nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestListMembership(t *testing.T) {
+	mux := newTestRouter(t)
+	owner := signUp(t, mux, "members_owner", "owner-password")
+	signUp(t, mux, "members_guest", "guest-password")
+
+	listPath := createList(t, mux, owner, "Работа")
+
+	resp := serveJSON(t, mux, http.MethodPost, listPath+"/members", owner, map[string]any{"login": "nobody_here"})
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Contains(t, resp.Body.String(), "user_not_found")
+
+	resp = serveJSON(t, mux, http.MethodPost, listPath+"/members", owner, map[string]any{"login": "members_guest", "role": "admin"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	invite(t, mux, owner, listPath, "members_guest", "viewer")
+
+	resp = serveJSON(t, mux, http.MethodPost, listPath+"/members", owner, map[string]any{"login": "members_guest"})
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "member_exists")
+
+	ownerID := jwtClaims(t, owner)["sub"]
+	resp = serveJSON(t, mux, http.MethodPut, fmt.Sprintf("%s/members/%v", listPath, ownerID), owner, map[string]any{"role": "viewer"})
+	assert.Equal(t, http.StatusConflict, resp.Code, "последний владелец не может понизить себя")
+	assert.Contains(t, resp.Body.String(), "last_owner")
+
+	resp = serveJSON(t, mux, http.MethodDelete, fmt.Sprintf("%s/members/%v", listPath, ownerID), owner, nil)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodGet, "/api/v1/lists", owner, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "Работа")
+
+	resp = serveJSON(t, mux, http.MethodGet, "/api/v1/lists/abc/tasks", owner, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
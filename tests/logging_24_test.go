@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/logger"
+)
+
+// captureLogs sends slog output to a buffer as JSON lines for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logger.New(&buf, "json", slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for scanner.Scan() {
+		var line map[string]any
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestRequestIDHeader(t *testing.T) {
+	mux := newTestRouter(t)
+
+	resp := serveJSON(t, mux, http.MethodGet, "/api/openapi.json", "", nil)
+	generated := resp.Header().Get(middlewares.RequestIDHeader)
+	assert.NotEmpty(t, generated)
+
+	again := serveJSON(t, mux, http.MethodGet, "/api/openapi.json", "", nil)
+	assert.NotEqual(t, generated, again.Header().Get(middlewares.RequestIDHeader))
+
+	for _, tc := range []struct {
+		incoming string
+		reused   bool
+	}{
+		{"edge-7f3a.42:1", true},
+		{"has spaces", false},
+		{"<script>", false},
+		{strings.Repeat("a", 129), false},
+	} {
+		request := httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+		request.Header.Set(middlewares.RequestIDHeader, tc.incoming)
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, request)
+
+		id := resp.Header().Get(middlewares.RequestIDHeader)
+		assert.NotEmpty(t, id)
+		assert.Equal(t, tc.reused, id == tc.incoming, tc.incoming)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	mux := newTestRouter(t)
+	buf := captureLogs(t)
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/tasks/12", nil)
+	request.Header.Set(middlewares.RequestIDHeader, "trace-1")
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, request)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	var access map[string]any
+	for _, line := range logLines(t, buf) {
+		// Every line written while serving the request carries its id.
+		assert.Equal(t, "trace-1", line["request_id"], line["msg"])
+		if line["msg"] == "request" {
+			access = line
+		}
+	}
+
+	if assert.NotNil(t, access) {
+		assert.Equal(t, "INFO", access["level"])
+		assert.Equal(t, http.MethodGet, access["method"])
+		assert.Equal(t, "/api/v1/tasks/{id}", access["route"])
+		assert.Equal(t, "/api/v1/tasks/12", access["path"])
+		assert.Equal(t, float64(http.StatusUnauthorized), access["status"])
+		assert.Contains(t, access, "latency")
+	}
+}
+
+func TestLoggerLevelAndFormat(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.New(&buf, "text", slog.LevelWarn)
+
+	log.Info("hidden")
+	log.Warn("shown", "key", "value")
+
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "level=WARN msg=shown key=value")
+}
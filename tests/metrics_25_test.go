@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, mux http.Handler, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, request)
+	return resp
+}
+
+func TestMetrics(t *testing.T) {
+	mux := newTestRouter(t)
+	pair := decodeTokens(t, serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{"password": "12345"}))
+
+	now := time.Now()
+	for _, date := range []time.Time{now, now, now.AddDate(0, 0, 3)} {
+		resp := serveBearer(t, mux, http.MethodPost, "/api/v1/tasks", pair.Token, map[string]any{
+			"date":  date.Format("2006-01-02"),
+			"title": "Метрики",
+		})
+		assert.Equal(t, http.StatusCreated, resp.Code)
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serveBearer(t, mux, http.MethodGet, "/api/v1/tasks/1", "garbage", nil).Code)
+
+	resp := scrape(t, mux, "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	body := resp.Body.String()
+
+	assert.Contains(t, body, `todo_http_requests_total{method="POST",route="/api/v1/tasks",status="201"}`)
+	assert.Contains(t, body, `todo_http_requests_total{method="GET",route="/api/v1/tasks/{id}",status="401"}`)
+	assert.Contains(t, body, `todo_http_request_duration_seconds_bucket{method="POST",route="/api/v1/tasks",le=`)
+	assert.Contains(t, body, `todo_storage_query_duration_seconds_count{method="AddTask"}`)
+	assert.Contains(t, body, `todo_auth_failures_total{reason="invalid_token"}`)
+	assert.Contains(t, body, `todo_tasks{state="today"} 2`)
+	assert.Contains(t, body, `todo_tasks{state="upcoming"} 1`)
+	assert.Contains(t, body, `todo_tasks{state="overdue"} 0`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestMetricsToken(t *testing.T) {
+	cfg := testConfig()
+	cfg.MetricsToken = "scrape-secret"
+	mux := newTestRouterWithConfig(t, cfg)
+
+	assert.Equal(t, http.StatusUnauthorized, scrape(t, mux, "").Code)
+	resp := scrape(t, mux, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.Equal(t, "application/problem+json", resp.Header().Get("Content-Type"))
+	assert.Contains(t, resp.Body.String(), "authentication_required")
+
+	resp = scrape(t, mux, "scrape-secret")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "todo_tasks")
+}
@@ -0,0 +1,283 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/oidc"
+)
+
+const oidcClientID = "todo-app"
+
+type fakeAuthorization struct {
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+// fakeIdP is a minimal OpenID Connect provider: discovery, JWKS, an
+// authorization endpoint that approves every request and a token endpoint
+// that checks PKCE.
+type fakeIdP struct {
+	server  *httptest.Server
+	key     *rsa.PrivateKey
+	subject string
+	login   string
+
+	mu    sync.Mutex
+	codes map[string]fakeAuthorization
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	idp := &fakeIdP{key: key, subject: "user-42", login: "alice", codes: map[string]fakeAuthorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(write http.ResponseWriter, request *http.Request) {
+		json.NewEncoder(write).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(write http.ResponseWriter, request *http.Request) {
+		json.NewEncoder(write).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(write http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		if query.Get("client_id") != oidcClientID || query.Get("code_challenge_method") != "S256" {
+			http.Error(write, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		code := rand.Text()
+		idp.mu.Lock()
+		idp.codes[code] = fakeAuthorization{
+			nonce:       query.Get("nonce"),
+			challenge:   query.Get("code_challenge"),
+			redirectURI: query.Get("redirect_uri"),
+		}
+		idp.mu.Unlock()
+
+		redirect, _ := url.Parse(query.Get("redirect_uri"))
+		values := redirect.Query()
+		values.Set("code", code)
+		values.Set("state", query.Get("state"))
+		redirect.RawQuery = values.Encode()
+		http.Redirect(write, request, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(write http.ResponseWriter, request *http.Request) {
+		idp.mu.Lock()
+		authorization, ok := idp.codes[request.FormValue("code")]
+		delete(idp.codes, request.FormValue("code"))
+		idp.mu.Unlock()
+
+		if !ok || request.FormValue("redirect_uri") != authorization.redirectURI ||
+			oidc.CodeChallenge(request.FormValue("code_verifier")) != authorization.challenge {
+			http.Error(write, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		json.NewEncoder(write).Encode(map[string]string{
+			"access_token": "opaque",
+			"token_type":   "Bearer",
+			"id_token":     idp.idToken(t, idp.key, authorization.nonce),
+		})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *fakeIdP) idToken(t *testing.T, key *rsa.PrivateKey, nonce string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                idp.server.URL,
+		"aud":                oidcClientID,
+		"sub":                idp.subject,
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"nonce":              nonce,
+		"preferred_username": idp.login,
+	})
+	token.Header["kid"] = "test-key"
+
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err)
+	return signed
+}
+
+func newOIDCRouter(t *testing.T, idp *fakeIdP) *chi.Mux {
+	cfg := testConfig()
+	cfg.OIDCIssuer = idp.server.URL
+	cfg.OIDCClientID = oidcClientID
+	cfg.OIDCRedirectURL = "http://app.test/api/oidc/callback"
+	return newTestRouterWithConfig(t, cfg)
+}
+
+// oidcSignIn walks the browser through login, the provider and the callback
+// and returns the final response of the app.
+func oidcSignIn(t *testing.T, mux *chi.Mux) *httptest.ResponseRecorder {
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/oidc/login", nil))
+	assert.Equal(t, http.StatusFound, resp.Code)
+
+	authorize, err := url.Parse(resp.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "S256", authorize.Query().Get("code_challenge_method"))
+	assert.NotEmpty(t, authorize.Query().Get("code_challenge"))
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	providerResp, err := noRedirect.Get(authorize.String())
+	assert.NoError(t, err)
+	providerResp.Body.Close()
+	assert.Equal(t, http.StatusFound, providerResp.StatusCode)
+
+	callback, err := url.Parse(providerResp.Header.Get("Location"))
+	assert.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	for _, cookie := range resp.Result().Cookies() {
+		request.AddCookie(cookie)
+	}
+
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, request)
+	return resp
+}
+
+func cookieValue(resp *httptest.ResponseRecorder, name string) string {
+	for _, cookie := range resp.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	idp := newFakeIdP(t)
+	mux := newOIDCRouter(t, idp)
+
+	resp := oidcSignIn(t, mux)
+	assert.Equal(t, http.StatusFound, resp.Code, resp.Body.String())
+	assert.Equal(t, "/", resp.Header().Get("Location"))
+
+	token := cookieValue(resp, "token")
+	assert.NotEmpty(t, token)
+	assert.NotEmpty(t, cookieValue(resp, "refresh_token"))
+
+	resp = serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", token, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	firstUser := jwtClaims(t, token)["sub"]
+	assert.NotEqual(t, "1", firstUser, "вход через OIDC не должен давать права администратора")
+
+	resp = oidcSignIn(t, mux)
+	assert.Equal(t, http.StatusFound, resp.Code)
+	assert.Equal(t, firstUser, jwtClaims(t, cookieValue(resp, "token"))["sub"], "тот же subject — тот же пользователь")
+
+	// Локальный пользователь с тем же логином не должен получить доступ к этой учётной записи.
+	resp = serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{"login": "alice", "password": ""})
+	assert.NotEqual(t, http.StatusOK, resp.Code)
+
+	idp.subject = "user-43"
+	resp = oidcSignIn(t, mux)
+	assert.Equal(t, http.StatusFound, resp.Code)
+	assert.NotEqual(t, firstUser, jwtClaims(t, cookieValue(resp, "token"))["sub"])
+}
+
+func TestOIDCRejectsInvalidCallback(t *testing.T) {
+	idp := newFakeIdP(t)
+	mux := newOIDCRouter(t, idp)
+
+	login := httptest.NewRecorder()
+	mux.ServeHTTP(login, httptest.NewRequest(http.MethodGet, "/api/oidc/login", nil))
+	authorize, _ := url.Parse(login.Header().Get("Location"))
+	state := authorize.Query().Get("state")
+	flowCookie := cookieValue(login, "oidc_state")
+	assert.NotContains(t, flowCookie, state, "state, nonce и verifier в куке зашифрованы")
+
+	callback := func(query string, cookie string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/api/oidc/callback?"+query, nil)
+		if cookie != "" {
+			request.AddCookie(&http.Cookie{Name: "oidc_state", Value: cookie})
+		}
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, request)
+		return resp
+	}
+
+	resp := callback("code=x&state="+url.QueryEscape(state), "")
+	assert.Equal(t, http.StatusUnauthorized, resp.Code, "без куки state вход отклоняется")
+
+	resp = callback("code=x&state="+url.QueryEscape(state), state)
+	assert.Contains(t, resp.Body.String(), "oidc_invalid_state", "поддельная кука отклоняется")
+
+	tampered := []byte(flowCookie)
+	tampered[len(tampered)/2] ^= 1
+	resp = callback("code=x&state="+url.QueryEscape(state), string(tampered))
+	assert.Contains(t, resp.Body.String(), "oidc_invalid_state")
+
+	resp = callback("code=x&state=other", flowCookie)
+	assert.Contains(t, resp.Body.String(), "oidc_invalid_state", "state из запроса должен совпадать с кукой")
+
+	resp = callback("code=unknown&state="+url.QueryEscape(state), flowCookie)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.Contains(t, resp.Body.String(), "oidc_failed")
+
+	resp = callback("error=access_denied", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+func TestOIDCVerify(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := oidc.NewProvider(idp.server.URL, oidcClientID, "", "http://app.test/api/oidc/callback")
+
+	claims, err := provider.Verify(idp.idToken(t, idp.key, "n1"), "n1")
+	assert.NoError(t, err)
+	assert.Equal(t, "user-42", claims.Subject)
+	assert.Equal(t, "alice", claims.PreferredUsername)
+
+	_, err = provider.Verify(idp.idToken(t, idp.key, "n1"), "n2")
+	assert.True(t, errors.Is(err, oidc.ErrInvalidToken))
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	_, err = provider.Verify(idp.idToken(t, otherKey, "n1"), "n1")
+	assert.True(t, errors.Is(err, oidc.ErrInvalidToken), "подпись чужим ключом")
+
+	provider.ClientID = "another-client"
+	_, err = provider.Verify(idp.idToken(t, idp.key, "n1"), "n1")
+	assert.True(t, errors.Is(err, oidc.ErrInvalidToken), "чужая аудитория")
+}
+
+func TestOIDCDisabled(t *testing.T) {
+	resp := httptest.NewRecorder()
+	newTestRouter(t).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/oidc/login", nil))
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Contains(t, resp.Body.String(), "oidc_disabled")
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/config"
+	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/http-server/openapi"
+	"todo_restapi/internal/http-server/router"
+	"todo_restapi/internal/storage"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Password:          "12345",
+		SecretKey:         "test_secret",
+		IdempotencyTTL:    time.Hour,
+		AllowRegistration: true,
+		AccessTokenTTL:    15 * time.Minute,
+		RefreshTokenTTL:   time.Hour,
+	}
+}
+
+func newTestRouter(t *testing.T) *chi.Mux {
+	return newTestRouterWithConfig(t, testConfig())
+}
+
+func newTestRouterWithConfig(t *testing.T, cfg *config.Config) *chi.Mux {
+	mux, _ := newTestRouterWithAuth(t, cfg)
+	return mux
+}
+
+func newTestRouterWithAuth(t *testing.T, cfg *config.Config) (*chi.Mux, *middlewares.AuthService) {
+	database, err := storage.OpenStorage(filepath.Join(t.TempDir(), "scheduler.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { database.CloseStorage() })
+
+	shared := config.NewShared(cfg)
+	authService := middlewares.NewAuthService(shared, database)
+	mux, err := router.NewWithAuth(shared, database, authService)
+	assert.NoError(t, err)
+	return mux, authService
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	doc, err := openapi.Load()
+	assert.NoError(t, err)
+
+	documented := map[string]bool{}
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+
+	registered := map[string]bool{}
+	err = chi.Walk(newTestRouter(t), func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/api/") || strings.HasPrefix(route, "/.well-known/") {
+			registered[method+" "+route] = true
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.NotEmpty(t, registered)
+	assert.Equal(t, documented, registered, "openapi.json должен описывать все маршруты /api")
+}
+
+func TestOpenAPIValidation(t *testing.T) {
+	mux := newTestRouter(t)
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, string(openapi.Spec), resp.Body.String())
+
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/nextdate?now=today&date=20240101&repeat=y", nil))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, "application/problem+json", resp.Header().Get("Content-Type"))
+
+	var problem struct {
+		Code   string `json:"code"`
+		Errors []struct {
+			Field string `json:"field"`
+		} `json:"errors"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &problem))
+	assert.Equal(t, "validation_failed", problem.Code)
+	if assert.Len(t, problem.Errors, 1) {
+		assert.Equal(t, "now", problem.Errors[0].Field)
+	}
+
+	resp = httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/api/signin", strings.NewReader(`{"password": 12345}`))
+	request.Header.Set("Content-Type", "application/json")
+	mux.ServeHTTP(resp, request)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/nextdate?now=20240126&date=20240101&repeat=y", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "20250101", resp.Body.String())
+}
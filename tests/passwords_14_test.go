@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/services"
+)
+
+func TestPasswordHashes(t *testing.T) {
+	bcryptHash, err := services.HashPassword("correct horse")
+	assert.NoError(t, err)
+	argon2Hash, err := services.HashPasswordArgon2id("correct horse")
+	assert.NoError(t, err)
+
+	for _, hash := range []string{bcryptHash, argon2Hash} {
+		assert.True(t, services.IsPasswordHash(hash), hash)
+		assert.True(t, services.CheckPassword(hash, "correct horse"), hash)
+		assert.False(t, services.CheckPassword(hash, "correct horse "), hash)
+		assert.False(t, services.CheckPassword(hash, ""), hash)
+	}
+
+	assert.True(t, strings.HasPrefix(argon2Hash, "$argon2id$v=19$"))
+	assert.False(t, services.IsPasswordHash("12345"))
+	assert.False(t, services.CheckPassword("$argon2id$v=19$broken", "correct horse"))
+}
+
+func jwtClaims(t *testing.T, token string) map[string]any {
+	parts := strings.Split(token, ".")
+	assert.Len(t, parts, 3)
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(t, err)
+
+	var claims map[string]any
+	assert.NoError(t, json.Unmarshal(payload, &claims))
+	return claims
+}
+
+func TestAdminHashedPassword(t *testing.T) {
+	for _, hashFunc := range []func(string) (string, error){services.HashPassword, services.HashPasswordArgon2id} {
+		hash, err := hashFunc("admin-secret")
+		assert.NoError(t, err)
+
+		cfg := testConfig()
+		cfg.Password = hash
+		mux := newTestRouterWithConfig(t, cfg)
+
+		resp := serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{"password": hash})
+		assert.Equal(t, http.StatusUnauthorized, resp.Code, "хеш не должен приниматься как пароль")
+
+		resp = serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{"password": "admin-secret"})
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var signin struct {
+			Token string `json:"token"`
+		}
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &signin))
+
+		claims := jwtClaims(t, signin.Token)
+		assert.NotContains(t, claims, "pwd")
+		assert.Equal(t, "1", claims["sub"])
+
+		resp = serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", signin.Token, nil)
+		assert.Equal(t, http.StatusOK, resp.Code)
+	}
+}
+
+func TestTokenHasNoPasswordData(t *testing.T) {
+	mux := newTestRouter(t)
+	token := signUp(t, mux, "carol", "carol-password")
+
+	claims := jwtClaims(t, token)
+	assert.NotContains(t, claims, "pwd")
+	payload, err := json.Marshal(claims)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(payload), "carol-password")
+}
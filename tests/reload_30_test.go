@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/config"
+	"todo_restapi/internal/http-server/middlewares"
+	"todo_restapi/internal/http-server/router"
+)
+
+func loadSharedConfig(t *testing.T, path string) (*config.Shared, *config.Flags) {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := config.RegisterFlags(set)
+	assert.NoError(t, set.Parse([]string{"-config", path}))
+
+	cfg, err := config.Load(flags)
+	assert.NoError(t, err)
+	return config.NewShared(cfg), flags
+}
+
+func rewriteConfigFile(t *testing.T, path, content string) {
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestConfigReload(t *testing.T) {
+	clearConfigEnv(t)
+
+	path := writeConfigFile(t, "todo.yaml", "password: first-password\nsecret: first-secret\nport: 7001\n")
+	shared, flags := loadSharedConfig(t, path)
+
+	database := openTestStorage(t)
+	mux, err := router.NewWithAuth(shared, database, middlewares.NewAuthService(shared, database))
+	assert.NoError(t, err)
+
+	signIn := func(password string) int {
+		return serveJSON(t, mux, http.MethodPost, "/api/signin", "", map[string]any{"password": password}).Code
+	}
+	assert.Equal(t, http.StatusOK, signIn("first-password"))
+	before := decodeTokens(t, serveJSON(t, mux, http.MethodPost, "/api/signin", "", map[string]any{"password": "first-password"})).Token
+
+	logs := captureLogs(t)
+	rewriteConfigFile(t, path, "password: second-password\nsecret: second-secret\nport: 7002\naccess_token_ttl: 1m\n")
+	assert.NoError(t, shared.Reload(flags))
+
+	assert.Equal(t, http.StatusUnauthorized, signIn("first-password"))
+	assert.Equal(t, http.StatusOK, signIn("second-password"))
+	assert.Equal(t, http.StatusUnauthorized, serveJSON(t, mux, http.MethodGet, "/api/tasks", before, nil).Code,
+		"токены, подписанные старым секретом, недействительны")
+	assert.Equal(t, time.Minute, shared.Get().AccessTokenTTL)
+	assert.Equal(t, ":7001", shared.Get().Port, "порт меняется только после перезапуска")
+
+	changed := map[string]map[string]any{}
+	for _, line := range logLines(t, logs) {
+		if line["msg"] == "config changed" || line["msg"] == "config change needs a restart, ignored" {
+			changed[line["field"].(string)] = line
+		}
+	}
+	assert.Contains(t, changed, "AccessTokenTTL")
+	assert.Contains(t, changed, "Port")
+	assert.NotContains(t, changed["Password"], "new", "пароль не попадает в лог")
+	assert.NotContains(t, logs.String(), "second-password")
+	assert.NotContains(t, logs.String(), "second-secret")
+}
+
+func TestConfigReloadRejected(t *testing.T) {
+	clearConfigEnv(t)
+
+	path := writeConfigFile(t, "todo.yaml", "password: first-password\nlog_level: info\n")
+	shared, flags := loadSharedConfig(t, path)
+	current := shared.Get()
+
+	rewriteConfigFile(t, path, "password: second-password\nlog_level: loud\n")
+	assert.ErrorContains(t, shared.Reload(flags), "log_level")
+	assert.Same(t, current, shared.Get())
+
+	rewriteConfigFile(t, path, "password: [unclosed\n")
+	assert.Error(t, shared.Reload(flags))
+	assert.Equal(t, "first-password", shared.Get().Password)
+}
+
+func TestConfigWatch(t *testing.T) {
+	clearConfigEnv(t)
+
+	path := writeConfigFile(t, "todo.yaml", "log_level: info\nrefresh_token_ttl: 1h\n")
+	shared, flags := loadSharedConfig(t, path)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- shared.Watch(ctx, flags) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+
+	assert.Eventually(t, func() bool {
+		rewriteConfigFile(t, path, "log_level: debug\nrefresh_token_ttl: 2h\n")
+		return shared.Get().RefreshTokenTTL == 2*time.Hour
+	}, 5*time.Second, 200*time.Millisecond)
+	assert.Equal(t, slog.LevelDebug, shared.LogLevel().Level())
+
+	t.Setenv("TODO_LOG_LEVEL", "warn")
+	process, err := os.FindProcess(os.Getpid())
+	assert.NoError(t, err)
+	assert.NoError(t, process.Signal(syscall.SIGHUP))
+	assert.Eventually(t, func() bool {
+		return shared.LogLevel().Level() == slog.LevelWarn
+	}, 5*time.Second, 50*time.Millisecond)
+
+	rewriteConfigFile(t, path, "not: [valid\n")
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, 2*time.Hour, shared.Get().RefreshTokenTTL, "неверный файл не сбрасывает настройки")
+}
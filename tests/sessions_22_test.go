@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const firefoxUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:125.0) Gecko/20100101 Firefox/125.0"
+
+type session struct {
+	ID        string `json:"id"`
+	Device    string `json:"device"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Current   bool   `json:"current"`
+}
+
+func signInFrom(t *testing.T, mux *chi.Mux, login string, password string, remoteAddr string, userAgent string) string {
+	data, err := json.Marshal(map[string]any{"login": login, "password": password})
+	assert.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/signin", bytes.NewReader(data))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", userAgent)
+	request.RemoteAddr = remoteAddr
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, request)
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	return decodeTokens(t, resp).Token
+}
+
+func listSessions(t *testing.T, mux *chi.Mux, token string) []session {
+	resp := serveJSON(t, mux, http.MethodGet, "/api/sessions", token, nil)
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var list struct {
+		Sessions []session `json:"sessions"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &list))
+	return list.Sessions
+}
+
+func TestSessions(t *testing.T) {
+	mux := newTestRouter(t)
+	first := signUp(t, mux, "sessions_user", "sessions-password")
+	second := signInFrom(t, mux, "sessions_user", "sessions-password", "203.0.113.5:41000", firefoxUserAgent)
+
+	sessions := listSessions(t, mux, second)
+	assert.Len(t, sessions, 2)
+
+	var current, other session
+	for _, s := range sessions {
+		if s.Current {
+			current = s
+		} else {
+			other = s
+		}
+	}
+	assert.Equal(t, "Firefox, Windows", current.Device)
+	assert.Equal(t, "203.0.113.5", current.IP)
+	assert.Equal(t, firefoxUserAgent, current.UserAgent)
+	assert.NotEmpty(t, other.ID)
+
+	intruder := signUp(t, mux, "sessions_intruder", "intruder-password")
+	resp := serveJSON(t, mux, http.MethodDelete, "/api/sessions/"+other.ID, intruder, nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code, "чужой сеанс завершить нельзя")
+	assert.Contains(t, resp.Body.String(), "session_not_found")
+
+	resp = serveJSON(t, mux, http.MethodDelete, "/api/sessions/"+other.ID, second, nil)
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", first, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code, "токен завершённого сеанса больше не действует")
+	assert.Len(t, listSessions(t, mux, second), 1)
+
+	resp = serveJSON(t, mux, http.MethodDelete, "/api/sessions/"+other.ID, second, nil)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodDelete, "/api/sessions/"+current.ID, second, nil)
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	assert.Contains(t, resp.Header().Get("Set-Cookie"), "token=;", "завершение текущего сеанса удаляет куки")
+
+	resp = serveJSON(t, mux, http.MethodGet, "/api/sessions", second, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+func TestSessionsRequireSignIn(t *testing.T) {
+	mux := newTestRouter(t)
+	token := signUp(t, mux, "sessions_keys", "sessions-password")
+
+	resp := serveJSON(t, mux, http.MethodPost, "/api/keys", token, map[string]any{"name": "script", "scopes": []string{"tasks:read"}})
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var created struct {
+		Key string `json:"key"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+
+	resp = serveBearer(t, mux, http.MethodGet, "/api/sessions", created.Key, nil)
+	assert.Equal(t, http.StatusForbidden, resp.Code, "ключ API не управляет сеансами")
+}
+
+func TestSessionTouchThrottled(t *testing.T) {
+	mux, authService := newTestRouterWithAuth(t, testConfig())
+	now := time.Now()
+	authService.Now = func() time.Time { return now }
+
+	token := decodeTokens(t, serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{"password": "12345"})).Token
+	recorder := recordSpans(t)
+
+	touches := func() int {
+		count := 0
+		for _, span := range recorder.Ended() {
+			if span.Name() == "storage.TouchSession" {
+				count++
+			}
+		}
+		return count
+	}
+
+	for i := 0; i < 5; i++ {
+		resp := serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", token, nil)
+		assert.Equal(t, http.StatusOK, resp.Code)
+	}
+	assert.Equal(t, 1, touches(), "чтение не пишет в базу на каждый запрос")
+
+	now = now.Add(2 * time.Minute)
+	serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", token, nil)
+	assert.Equal(t, 2, touches())
+}
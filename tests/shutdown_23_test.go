@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/http-server/server"
+)
+
+// startServer serves handler on a random port and returns its address, the
+// cancel that triggers shutdown and the channel Serve reports to.
+func startServer(t *testing.T, handler http.Handler, shutdownTimeout time.Duration) (string, *server.Server, context.CancelFunc, chan error) {
+	cfg := testConfig()
+	cfg.ReadTimeout = 5 * time.Second
+	cfg.WriteTimeout = 5 * time.Second
+	cfg.IdleTimeout = 5 * time.Second
+	cfg.ShutdownTimeout = shutdownTimeout
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	srv := server.New(cfg, handler)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, listener) }()
+
+	t.Cleanup(cancel)
+	return "http://" + listener.Addr().String(), srv, cancel, done
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	handler := http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(write, "done")
+	})
+
+	address, srv, cancel, done := startServer(t, handler, 5*time.Second)
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(address + "/slow")
+		if err != nil {
+			inFlight <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		inFlight <- result{status: resp.StatusCode, body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	assert.Eventually(t, srv.Draining, time.Second, 10*time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("сервер остановился, не дождавшись запроса: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	res := <-inFlight
+	assert.NoError(t, res.err)
+	assert.Equal(t, http.StatusOK, res.status)
+	assert.Equal(t, "done", res.body, "начатый запрос должен завершиться")
+
+	assert.NoError(t, <-done)
+
+	_, err := http.Get(address + "/slow")
+	assert.Error(t, err, "после остановки новые соединения не принимаются")
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(write http.ResponseWriter, request *http.Request) {
+		close(started)
+		<-release
+	})
+
+	address, _, cancel, done := startServer(t, handler, 50*time.Millisecond)
+
+	go http.Get(address + "/stuck")
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err, "зависший запрос прерывается по истечении TODO_SHUTDOWN_TIMEOUT")
+	case <-time.After(2 * time.Second):
+		t.Fatal("сервер не остановился")
+	}
+}
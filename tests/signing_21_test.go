@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/signing"
+)
+
+func writeSigningKey(t *testing.T, alg string) string {
+	key, err := signing.GenerateKey(alg)
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), strings.ToLower(alg)+".pem")
+	assert.NoError(t, os.WriteFile(path, key, 0o600))
+	return path
+}
+
+func tokenHeader(t *testing.T, token string) map[string]any {
+	data, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	assert.NoError(t, err)
+
+	var header map[string]any
+	assert.NoError(t, json.Unmarshal(data, &header))
+	return header
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	edKey := writeSigningKey(t, "EdDSA")
+	rsaKey := writeSigningKey(t, "RS256")
+
+	cfg := testConfig()
+	cfg.SigningKeys = []string{edKey, rsaKey}
+	mux := newTestRouterWithConfig(t, cfg)
+
+	token := signUp(t, mux, "jwks_user", "jwks-password")
+	header := tokenHeader(t, token)
+	assert.Equal(t, "EdDSA", header["alg"])
+	assert.NotEmpty(t, header["kid"])
+
+	resp := serveJSON(t, mux, http.MethodGet, "/.well-known/jwks.json", "", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var jwks signing.JSONWebKeySet
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &jwks))
+	assert.Len(t, jwks.Keys, 2)
+	assert.NotContains(t, resp.Body.String(), `"d"`, "закрытые ключи не публикуются")
+
+	// Другой сервис проверяет токен только по JWKS.
+	var published signing.JSONWebKey
+	for _, key := range jwks.Keys {
+		if key.Kid == header["kid"] {
+			published = key
+		}
+	}
+	assert.Equal(t, "OKP", published.Kty)
+	x, err := base64.RawURLEncoding.DecodeString(published.X)
+	assert.NoError(t, err)
+
+	parsed, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return ed25519.PublicKey(x), nil },
+		jwt.WithValidMethods([]string{"EdDSA"}))
+	assert.NoError(t, err)
+	assert.True(t, parsed.Valid)
+
+	resp = serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", token, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	// Токен, подписанный общим секретом, больше не принимается.
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims(jwtClaims(t, token))).SignedString([]byte(cfg.SecretKey))
+	assert.NoError(t, err)
+	resp = serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", forged, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	oldKey := writeSigningKey(t, "RS256")
+	newKey := writeSigningKey(t, "EdDSA")
+
+	cfg := testConfig()
+	cfg.SigningKeys = []string{oldKey}
+	mux, authService := newTestRouterWithAuth(t, cfg)
+
+	oldToken := signUp(t, mux, "rotation_user", "rotation-password")
+	assert.Equal(t, "RS256", tokenHeader(t, oldToken)["alg"])
+
+	cfg.SigningKeys = []string{newKey, oldKey}
+	assert.NoError(t, authService.LoadSigningKeys())
+
+	resp := serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", oldToken, nil)
+	assert.Equal(t, http.StatusOK, resp.Code, "во время ротации старые токены действуют")
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{
+		"login": "rotation_user", "password": "rotation-password",
+	})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	newToken := decodeTokens(t, resp).Token
+	assert.Equal(t, "EdDSA", tokenHeader(t, newToken)["alg"])
+	assert.NotEqual(t, tokenHeader(t, oldToken)["kid"], tokenHeader(t, newToken)["kid"])
+
+	cfg.SigningKeys = []string{newKey}
+	assert.NoError(t, authService.LoadSigningKeys())
+
+	resp = serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", oldToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code, "после удаления старого ключа его токены недействительны")
+	resp = serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", newToken, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
+
+func TestSigningKeyValidation(t *testing.T) {
+	_, err := signing.ParseKey([]byte("not a key"))
+	assert.Error(t, err)
+
+	key, err := signing.ParseKey(mustRead(t, writeSigningKey(t, "EdDSA")))
+	assert.NoError(t, err)
+	assert.True(t, key.CanSign())
+
+	cfg := testConfig()
+	_, authService := newTestRouterWithAuth(t, cfg)
+	cfg.SigningKeys = []string{filepath.Join(t.TempDir(), "missing.pem")}
+	assert.Error(t, authService.LoadSigningKeys())
+
+	resp := serveJSON(t, newTestRouter(t), http.MethodGet, "/.well-known/jwks.json", "", nil)
+	assert.JSONEq(t, `{"keys": []}`, resp.Body.String(), "с общим секретом публиковать нечего")
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return data
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestStatus(t *testing.T, apipath string, values map[string]any, method string) (int, map[string]any) {
+	var data []byte
+	if len(values) > 0 {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return resp.StatusCode, m
+}
+
+func TestStatusCodes(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	id := addTask(t, task{
+		date:  time.Now().Format(`20060102`),
+		title: "Проверка кодов ответа",
+	})
+	ret, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	edit := func(taskID string) map[string]any {
+		return map[string]any{
+			"id":    taskID,
+			"date":  time.Now().Format(`20060102`),
+			"title": "Несуществующая задача",
+		}
+	}
+
+	tbl := []struct {
+		method string
+		path   string
+		values map[string]any
+		status int
+		code   string
+	}{
+		{http.MethodGet, "api/task?id=" + id, nil, http.StatusNotFound, "task_not_found"},
+		{http.MethodGet, "api/task?id=abc", nil, http.StatusBadRequest, "validation_failed"},
+		{http.MethodGet, "api/task", nil, http.StatusBadRequest, "validation_failed"},
+		{http.MethodPut, "api/task", edit(id), http.StatusNotFound, "task_not_found"},
+		{http.MethodPut, "api/task", edit("abc"), http.StatusBadRequest, "validation_failed"},
+		{http.MethodDelete, "api/task?id=" + id, nil, http.StatusNotFound, "task_not_found"},
+		{http.MethodDelete, "api/task?id=abc", nil, http.StatusBadRequest, "validation_failed"},
+		{http.MethodPost, "api/task/done?id=" + id, nil, http.StatusNotFound, "task_not_found"},
+		{http.MethodPost, "api/task/done?id=abc", nil, http.StatusBadRequest, "validation_failed"},
+	}
+	for _, v := range tbl {
+		status, m := requestStatus(t, v.path, v.values, v.method)
+		assert.Equal(t, v.status, status, "%s %s", v.method, v.path)
+		assert.Equal(t, v.code, m["code"], "%s %s", v.method, v.path)
+		assert.NotEmpty(t, m["error"], "%s %s", v.method, v.path)
+	}
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type tokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func decodeTokens(t *testing.T, resp *httptest.ResponseRecorder) tokenPair {
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var pair tokenPair
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pair))
+	assert.NotEmpty(t, pair.Token)
+	assert.NotEmpty(t, pair.RefreshToken)
+	return pair
+}
+
+func refresh(t *testing.T, mux *chi.Mux, refreshToken string) *httptest.ResponseRecorder {
+	return serveJSON(t, mux, http.MethodPost, "/api/token/refresh", "", map[string]any{"refresh_token": refreshToken})
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	mux, authService := newTestRouterWithAuth(t, testConfig())
+	now := time.Now()
+	authService.Now = func() time.Time { return now }
+
+	first := decodeTokens(t, serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{"password": "12345"}))
+	assert.Equal(t, int64(15*60), first.ExpiresIn)
+
+	second := decodeTokens(t, refresh(t, mux, first.RefreshToken))
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	assert.NotEqual(t, first.Token, second.Token)
+
+	resp := serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", second.Token, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	// Другая вкладка, обновившая тот же токен в пределах нескольких секунд,
+	// получает новую пару той же цепочки.
+	now = now.Add(5 * time.Second)
+	third := decodeTokens(t, refresh(t, mux, first.RefreshToken))
+
+	// Повторное использование уже обменянного токена после короткого окна
+	// отзывает всю цепочку.
+	now = now.Add(10 * time.Second)
+	resp = refresh(t, mux, first.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.Contains(t, resp.Body.String(), "refresh_token_reused")
+
+	for _, pair := range []tokenPair{second, third} {
+		resp = refresh(t, mux, pair.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		assert.Contains(t, resp.Body.String(), "invalid_refresh_token")
+	}
+
+	for _, token := range []string{first.Token, second.Token, third.Token} {
+		resp = serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", token, nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	}
+
+	resp = refresh(t, mux, "unknown")
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+func TestConcurrentRefresh(t *testing.T) {
+	mux := newTestRouter(t)
+
+	session := decodeTokens(t, serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{"password": "12345"}))
+
+	// Несколько вкладок одновременно обновляют один и тот же токен.
+	responses := make([]*httptest.ResponseRecorder, 5)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = refresh(t, mux, session.RefreshToken)
+		}()
+	}
+	wg.Wait()
+
+	for _, resp := range responses {
+		pair := decodeTokens(t, resp)
+		resp = serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", pair.Token, nil)
+		assert.Equal(t, http.StatusOK, resp.Code, "сессия не отзывается")
+		decodeTokens(t, refresh(t, mux, pair.RefreshToken))
+	}
+}
+
+func TestSignOut(t *testing.T) {
+	mux := newTestRouter(t)
+
+	session := decodeTokens(t, serveJSON(t, mux, http.MethodPost, "/api/signin", "", map[string]any{"password": "12345"}))
+	other := decodeTokens(t, serveJSON(t, mux, http.MethodPost, "/api/signin", "", map[string]any{"password": "12345"}))
+
+	resp := serveJSON(t, mux, http.MethodPost, "/api/signout", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/signout", session.Token, nil)
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", session.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	resp = refresh(t, mux, session.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	// Другие входы того же пользователя продолжают работать.
+	resp = serveJSON(t, mux, http.MethodGet, "/api/v1/tasks", other.Token, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	decodeTokens(t, refresh(t, mux, other.RefreshToken))
+}
+
+func TestRefreshCookie(t *testing.T) {
+	mux := newTestRouter(t)
+
+	resp := serveJSON(t, mux, http.MethodPost, "/api/signin", "", map[string]any{"password": "12345"})
+	decodeTokens(t, resp)
+
+	var refreshCookie *http.Cookie
+	for _, cookie := range resp.Result().Cookies() {
+		if cookie.Name == "refresh_token" {
+			refreshCookie = cookie
+		}
+	}
+	if !assert.NotNil(t, refreshCookie) {
+		return
+	}
+	assert.True(t, refreshCookie.HttpOnly)
+
+	request := httptest.NewRequest(http.MethodPost, "/api/token/refresh", nil)
+	request.AddCookie(refreshCookie)
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, request)
+	pair := decodeTokens(t, resp)
+
+	cookies := map[string]string{}
+	for _, cookie := range resp.Result().Cookies() {
+		cookies[cookie.Name] = cookie.Value
+	}
+	assert.Equal(t, pair.Token, cookies["token"])
+	assert.Equal(t, pair.RefreshToken, cookies["refresh_token"])
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"todo_restapi/internal/services"
+)
+
+func TestTOTPCodes(t *testing.T) {
+	// Тестовые векторы RFC 6238 для SHA1, последние 6 цифр.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		code, err := services.TOTPCode(secret, services.TOTPStep(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, want, code, unix)
+	}
+
+	now := time.Unix(1234567890, 0)
+	for _, shift := range []time.Duration{-30 * time.Second, 0, 30 * time.Second} {
+		code, _ := services.TOTPCode(secret, services.TOTPStep(now.Add(shift)))
+		_, ok := services.ValidateTOTP(secret, code, now)
+		assert.True(t, ok, shift)
+	}
+	code, _ := services.TOTPCode(secret, services.TOTPStep(now.Add(-90*time.Second)))
+	_, ok := services.ValidateTOTP(secret, code, now)
+	assert.False(t, ok)
+
+	uri, err := url.Parse(services.TOTPProvisioningURI("Todo Scheduler", "alice", secret))
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, secret, uri.Query().Get("secret"))
+	assert.Equal(t, "Todo Scheduler", uri.Query().Get("issuer"))
+}
+
+func TestTOTPSignIn(t *testing.T) {
+	now := time.Date(2024, 1, 26, 12, 0, 0, 0, time.UTC)
+	mux, authService := newTestRouterWithAuth(t, testConfig())
+	authService.Now = func() time.Time { return now }
+
+	credentials := map[string]any{"login": "alice", "password": "alice-password"}
+	alice := signUp(t, mux, "alice", "alice-password")
+
+	resp := serveJSON(t, mux, http.MethodPost, "/api/2fa/enroll", alice, nil)
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var enrollment struct {
+		Secret string `json:"secret"`
+		URI    string `json:"uri"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &enrollment))
+	assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/"))
+
+	codeAt := func(at time.Time) string {
+		code, err := services.TOTPCode(enrollment.Secret, services.TOTPStep(at))
+		assert.NoError(t, err)
+		return code
+	}
+
+	// Пока код не подтверждён, вход работает по одному паролю.
+	resp = serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", credentials)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/2fa/verify", alice, map[string]any{"code": "000000"})
+	assert.Equal(t, http.StatusBadRequest, resp.Code, "опечатка в коде не выглядит как конец сеанса")
+	assert.Contains(t, resp.Body.String(), "invalid_otp")
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/2fa/verify", alice, map[string]any{"code": codeAt(now)})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var recovery struct {
+		Codes []string `json:"recovery_codes"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &recovery))
+	assert.Len(t, recovery.Codes, 10)
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", credentials)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.Contains(t, resp.Body.String(), "otp_required")
+
+	withCode := func(field string, code string) map[string]any {
+		return map[string]any{"login": "alice", "password": "alice-password", field: code}
+	}
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/signin", "", withCode("otp", codeAt(now)))
+	assert.Equal(t, http.StatusUnauthorized, resp.Code, "код, уже использованный при подключении, повторно не принимается")
+	assert.Contains(t, resp.Body.String(), "invalid_otp")
+
+	now = now.Add(30 * time.Second)
+	resp = serveJSON(t, mux, http.MethodPost, "/api/signin", "", withCode("otp", codeAt(now)))
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", withCode("recovery_code", strings.ToUpper(recovery.Codes[0])))
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", withCode("recovery_code", recovery.Codes[0]))
+	assert.Equal(t, http.StatusUnauthorized, resp.Code, "резервный код одноразовый")
+
+	resp = serveJSON(t, mux, http.MethodGet, "/api/2fa", alice, nil)
+	assert.JSONEq(t, `{"enabled": true, "recovery_codes_left": 9}`, resp.Body.String())
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/2fa/disable", alice, map[string]any{"recovery_code": recovery.Codes[1]})
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", credentials)
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
+
+func TestTOTPDisableLockout(t *testing.T) {
+	mux, authService := newTestRouterWithAuth(t, testConfig())
+	bob := signUp(t, mux, "bob", "bob-password")
+
+	resp := serveJSON(t, mux, http.MethodPost, "/api/2fa/enroll", bob, nil)
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &enrollment))
+	code, err := services.TOTPCode(enrollment.Secret, services.TOTPStep(time.Now()))
+	assert.NoError(t, err)
+	resp = serveJSON(t, mux, http.MethodPost, "/api/2fa/verify", bob, map[string]any{"code": code})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	for i := 0; i < authService.Limiter.AccountAttempts; i++ {
+		resp = serveJSON(t, mux, http.MethodPost, "/api/2fa/disable", bob, map[string]any{"code": "000000"})
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Contains(t, resp.Body.String(), "invalid_otp")
+	}
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/2fa/disable", bob, map[string]any{"code": "000000"})
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code, "подбор кода блокируется")
+	assert.NotEmpty(t, resp.Header().Get("Retry-After"))
+
+	resp = serveJSON(t, mux, http.MethodGet, "/api/2fa", bob, nil)
+	assert.Contains(t, resp.Body.String(), `"enabled":true`)
+}
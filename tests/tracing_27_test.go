@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"todo_restapi/internal/storage"
+	"todo_restapi/internal/tracing"
+)
+
+// recordSpans installs an in-memory tracer provider for the rest of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	_, err := tracing.Setup(context.Background(), "", nil)
+	assert.NoError(t, err)
+	return recorder
+}
+
+func findSpan(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracingSpans(t *testing.T) {
+	mux := newTestRouter(t)
+	pair := decodeTokens(t, serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{"password": "12345"}))
+
+	recorder := recordSpans(t)
+	logs := captureLogs(t)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const parentID = "00f067aa0ba902b7"
+
+	data, err := json.Marshal(map[string]any{"date": time.Now().Format("2006-01-02"), "title": "Трассировка"})
+	assert.NoError(t, err)
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/tasks", bytes.NewReader(data))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+pair.Token)
+	request.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, request)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	spans := recorder.Ended()
+
+	server := findSpan(spans, "POST /api/v1/tasks")
+	if !assert.NotNil(t, server) {
+		return
+	}
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+	assert.Equal(t, traceID, server.SpanContext().TraceID().String())
+	assert.Equal(t, parentID, server.Parent().SpanID().String())
+	assert.True(t, server.Parent().IsRemote())
+	assert.Equal(t, "/api/v1/tasks", spanAttribute(server, "http.route").AsString())
+	assert.Equal(t, int64(http.StatusCreated), spanAttribute(server, "http.response.status_code").AsInt64())
+
+	for _, name := range []string{"storage.IsTokenRevoked", "storage.AddTask", "storage.GetTask"} {
+		span := findSpan(spans, name)
+		if assert.NotNil(t, span, name) {
+			assert.Equal(t, server.SpanContext().TraceID(), span.SpanContext().TraceID(), name)
+			assert.Equal(t, server.SpanContext().SpanID(), span.Parent().SpanID(), name)
+			assert.Equal(t, trace.SpanKindClient, span.SpanKind(), name)
+		}
+	}
+
+	// Log lines written while serving the request point at its span.
+	for _, line := range logLines(t, logs) {
+		if line["msg"] == "request" {
+			assert.Equal(t, traceID, line["trace_id"])
+			assert.Equal(t, server.SpanContext().SpanID().String(), line["span_id"])
+		}
+	}
+}
+
+func TestTracingStorageErrors(t *testing.T) {
+	database := openTestStorage(t)
+	recorder := recordSpans(t)
+	scope := storage.TaskScope{OwnerID: storage.AdminUserID}
+
+	_, err := database.GetTask(context.Background(), scope, "999")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	span := findSpan(recorder.Ended(), "storage.GetTask")
+	if assert.NotNil(t, span) {
+		assert.Equal(t, codes.Unset, span.Status().Code, "отсутствие задачи не ошибка")
+		assert.Empty(t, span.Events())
+	}
+
+	assert.NoError(t, database.CloseStorage())
+	_, err = database.GetTasks(context.Background(), scope)
+	assert.Error(t, err)
+
+	span = findSpan(recorder.Ended(), "storage.GetTasks")
+	if assert.NotNil(t, span) {
+		assert.Equal(t, codes.Error, span.Status().Code)
+		if assert.Len(t, span.Events(), 1) {
+			assert.Equal(t, "exception", span.Events()[0].Name)
+		}
+	}
+}
+
+func TestTracingNewTrace(t *testing.T) {
+	mux := newTestRouter(t)
+	recorder := recordSpans(t)
+
+	resp := serveJSON(t, mux, http.MethodGet, "/api/v1/tasks/5", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+	server := findSpan(recorder.Ended(), "GET /api/v1/tasks/{id}")
+	if assert.NotNil(t, server) {
+		assert.False(t, server.Parent().IsValid())
+		assert.True(t, server.SpanContext().IsValid())
+	}
+}
+
+func TestTracingStdoutExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	var out bytes.Buffer
+	shutdown, err := tracing.Setup(context.Background(), "stdout", &out)
+	assert.NoError(t, err)
+
+	mux := newTestRouter(t)
+	serveJSON(t, mux, http.MethodGet, "/healthz", "", nil)
+	assert.NoError(t, shutdown(context.Background()))
+
+	assert.True(t, strings.Contains(out.String(), `"Name":"GET /healthz"`), out.String())
+
+	_, err = tracing.Setup(context.Background(), "zipkin", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveJSON(t *testing.T, mux *chi.Mux, method string, path string, token string, values any) *httptest.ResponseRecorder {
+	var data []byte
+	if values != nil {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+
+	request := httptest.NewRequest(method, path, bytes.NewReader(data))
+	if values != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if len(token) > 0 {
+		request.AddCookie(&http.Cookie{Name: "token", Value: token})
+	}
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, request)
+	return resp
+}
+
+func signUp(t *testing.T, mux *chi.Mux, login string, password string) string {
+	resp := serveJSON(t, mux, http.MethodPost, "/api/v1/signup", "", map[string]any{
+		"login":    login,
+		"password": password,
+	})
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{
+		"login":    login,
+		"password": password,
+	})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var signin struct {
+		Token string `json:"token"`
+	}
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &signin))
+	assert.NotEmpty(t, signin.Token)
+	return signin.Token
+}
+
+func TestUserSignUp(t *testing.T) {
+	mux := newTestRouter(t)
+
+	signUp(t, mux, "alice", "alice-password")
+
+	resp := serveJSON(t, mux, http.MethodPost, "/api/v1/signup", "", map[string]any{
+		"login":    "alice",
+		"password": "another-password",
+	})
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), "user_exists")
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/v1/signup", "", map[string]any{
+		"login":    "bob",
+		"password": "short",
+	})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{
+		"login":    "alice",
+		"password": "wrong-password",
+	})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.Contains(t, resp.Body.String(), "invalid_credentials")
+
+	resp = serveJSON(t, mux, http.MethodPost, "/api/v1/signin", "", map[string]any{
+		"login":    "nobody",
+		"password": "alice-password",
+	})
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.Contains(t, resp.Body.String(), "invalid_credentials")
+}
+
+func TestUserIsolation(t *testing.T) {
+	mux := newTestRouter(t)
+	today := time.Now().Format("2006-01-02")
+
+	alice := signUp(t, mux, "alice", "alice-password")
+	bob := signUp(t, mux, "bob", "bob-password")
+
+	resp := serveJSON(t, mux, http.MethodPost, "/api/v1/tasks", alice, map[string]any{
+		"date":  today,
+		"title": "Задача Алисы",
+	})
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var created v1Task
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+	taskPath := fmt.Sprintf("/api/v1/tasks/%d", created.ID)
+
+	resp = serveJSON(t, mux, http.MethodGet, taskPath, alice, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	for _, check := range []struct {
+		method string
+		path   string
+		values any
+	}{
+		{http.MethodGet, taskPath, nil},
+		{http.MethodPut, taskPath, map[string]any{"date": today, "title": "Чужая задача"}},
+		{http.MethodPost, taskPath + "/done", nil},
+		{http.MethodDelete, taskPath, nil},
+		{http.MethodGet, fmt.Sprintf("/api/task?id=%d", created.ID), nil},
+	} {
+		resp = serveJSON(t, mux, check.method, check.path, bob, check.values)
+		assert.Equal(t, http.StatusNotFound, resp.Code, "%s %s", check.method, check.path)
+	}
+
+	for _, path := range []string{"/api/v1/tasks", "/api/v1/tasks?search=Алисы", "/api/v1/tasks?search=" + today} {
+		resp = serveJSON(t, mux, http.MethodGet, path, bob, nil)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var list struct {
+			Tasks []v1Task `json:"tasks"`
+		}
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &list))
+		assert.Empty(t, list.Tasks, path)
+	}
+
+	resp = serveJSON(t, mux, http.MethodGet, taskPath, alice, nil)
+	assert.Equal(t, http.StatusOK, resp.Code, "задача должна остаться у владельца")
+}
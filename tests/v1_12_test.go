@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type v1Task struct {
+	ID        int64     `json:"id"`
+	Date      string    `json:"date"`
+	Title     string    `json:"title"`
+	Comment   string    `json:"comment"`
+	Repeat    string    `json:"repeat"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func requestV1(t *testing.T, method string, apipath string, values any) (*http.Response, []byte) {
+	var data []byte
+	if values != nil {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	if values != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp, body
+}
+
+func TestV1Tasks(t *testing.T) {
+	now := time.Now()
+	today := now.Format("2006-01-02")
+
+	resp, body := requestV1(t, http.MethodPost, "api/v1/tasks", map[string]any{
+		"date":   today,
+		"title":  "Версия API",
+		"repeat": "d 2",
+	})
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Deprecation"))
+
+	var created v1Task
+	assert.NoError(t, json.Unmarshal(body, &created))
+	assert.NotZero(t, created.ID)
+	assert.Equal(t, today, created.Date)
+	assert.Equal(t, "Версия API", created.Title)
+	assert.False(t, created.CreatedAt.IsZero())
+	assert.Equal(t, fmt.Sprintf("/api/v1/tasks/%d", created.ID), resp.Header.Get("Location"))
+
+	taskPath := fmt.Sprintf("api/v1/tasks/%d", created.ID)
+
+	resp, body = requestV1(t, http.MethodGet, taskPath, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var fetched v1Task
+	assert.NoError(t, json.Unmarshal(body, &fetched))
+	assert.Equal(t, created, fetched)
+
+	resp, body = requestV1(t, http.MethodPut, taskPath, map[string]any{
+		"date":    today,
+		"title":   "Версия API v1",
+		"comment": "обновлено",
+		"repeat":  "d 2",
+	})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var updated v1Task
+	assert.NoError(t, json.Unmarshal(body, &updated))
+	assert.Equal(t, created.ID, updated.ID)
+	assert.Equal(t, "обновлено", updated.Comment)
+
+	resp, body = requestV1(t, http.MethodGet, "api/v1/tasks?search="+today, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var list struct {
+		Tasks []v1Task `json:"tasks"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &list))
+	found := false
+	for _, v := range list.Tasks {
+		found = found || v.ID == created.ID
+	}
+	assert.True(t, found, "задача должна находиться поиском по дате в формате ISO")
+
+	resp, body = requestV1(t, http.MethodPost, taskPath+"/done", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var done v1Task
+	assert.NoError(t, json.Unmarshal(body, &done))
+	assert.Equal(t, now.AddDate(0, 0, 2).Format("2006-01-02"), done.Date)
+
+	resp, _ = requestV1(t, http.MethodDelete, taskPath, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, body = requestV1(t, http.MethodGet, taskPath, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, string(body), "task_not_found")
+
+	resp, _ = requestV1(t, http.MethodPost, "api/v1/tasks", map[string]any{
+		"date":  "20240126",
+		"title": "Старый формат даты",
+	})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestV1NextDate(t *testing.T) {
+	resp, body := requestV1(t, http.MethodGet, "api/v1/nextdate?now=2024-01-26&date=2024-01-13&repeat=d%207", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"date": "2024-01-27"}`, string(body))
+}
+
+func TestLegacyDeprecation(t *testing.T) {
+	resp, _ := requestV1(t, http.MethodGet, "api/tasks", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get("Deprecation"))
+	assert.Contains(t, resp.Header.Get("Link"), `</api/v1>; rel="successor-version"`)
+}
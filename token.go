@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"todo_restapi/internal/config"
+	"todo_restapi/internal/http-server/middlewares"
+)
+
+// mintToken implements `todo_restapi token [-user login] [-ttl duration]`. It
+// signs the user in without a password and prints the access token, for
+// tests and scripts against a local server. The token is signed with the
+// configured keys and shows up, and can be revoked, like any other sign-in.
+func mintToken(args []string, stdout io.Writer) error {
+
+	flags := flag.NewFlagSet("token", flag.ContinueOnError)
+	login := flags.String("user", "", "login of the user, the admin by default")
+	ttl := flags.Duration("ttl", 0, "token lifetime, TODO_ACCESS_TOKEN_TTL by default")
+	cfg, err := loadConfig(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return errors.New("usage: token [-user login] [-ttl duration] [flags]")
+	}
+
+	if *ttl < 0 {
+		return errors.New("-ttl must be positive")
+	}
+	if *ttl > 0 {
+		cfg.AccessTokenTTL = *ttl
+	}
+
+	database, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer database.CloseStorage()
+
+	ctx := context.Background()
+
+	userID, err := findUser(ctx, database, *login)
+	if err != nil {
+		return err
+	}
+
+	authService := middlewares.NewAuthService(config.NewShared(cfg), database)
+	if err := authService.LoadSigningKeys(); err != nil {
+		return fmt.Errorf("LoadSigningKeys: %w", err)
+	}
+
+	pair, err := authService.StartSession(ctx, database, userID, middlewares.ClientInfo{UserAgent: "todo_restapi token"})
+	if err != nil {
+		return fmt.Errorf("StartSession: %w", err)
+	}
+
+	_, err = fmt.Fprintln(stdout, pair.AccessToken)
+	return err
+}